@@ -1,18 +1,42 @@
 package config
 
 import (
+	"bytes"
+	"crypto/ed25519"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"reflect"
+	"strings"
+	"sync/atomic"
 )
 
 type AppConfig struct {
 	InstallPath string `json:"installPath"`
 	ExecName    string `json:"execName"`
 	LogFileName string `json:"logFileName"`
+	// Minimum level of the logged events, e.g. "info"
+	LogLevel string `json:"logLevel"`
+	// Format of the log lines: "console", "json" or "logfmt"
+	LogFormat string `json:"logFormat"`
+	// Megabytes after which the log file is rotated
+	LogMaxSize int `json:"logMaxSize"`
+	// Days after which the log file is rotated
+	LogMaxAge int `json:"logMaxAge"`
+	// Number of compressed old log files kept
+	LogMaxBackups int `json:"logMaxBackups"`
+	// Log daemon also receiving the logs: "", "journald" or "syslog"
+	LogBackend string `json:"logBackend"`
+	// Syslog server of the "syslog" backend, e.g. "udp://127.0.0.1:514"
+	SyslogAddress string `json:"syslogAddress"`
+
 	ConfigName  string `json:"configName"`
 	ServiceName string `json:"serviceName"`
+	// Seconds between two enforcement checks of the service
+	CheckInterval int `json:"checkInterval"`
+	// Run a guardian process restarting the service when it is stopped
+	Guardian bool `json:"guardian"`
 }
 
 type WarpConfig struct {
@@ -20,72 +44,254 @@ type WarpConfig struct {
 	GUIExecName string `json:"guiExecName"`
 	SvcExecName string `json:"svcExecName"`
 	ServiceName string `json:"serviceName"`
+	// Mode Warp has to run in (warp, doh, dot, warp+doh, warp+dot, proxy, tunnel_only), empty to not enforce any
+	RequiredMode string `json:"requiredMode"`
+	// Minimal Cloudflare for Families DNS filtering level (off, malware, full)
+	FamiliesMode string `json:"familiesMode"`
+	// Seconds to wait before restarting the service after each consecutive failure
+	RestartDelays []int `json:"restartDelays"`
+	// Seconds without failures after which the failure count is reset, Windows only as
+	// systemd keeps no failure count
+	RestartResetPeriod int `json:"restartResetPeriod"`
 }
 
-var App *AppConfig
-var Warp *WarpConfig
+// Effective configs of one load, never modified once published
+type Snapshot struct {
+	App  *AppConfig
+	Warp *WarpConfig
 
-type combinedConfigs struct {
-	app  *AppConfig
-	warp *WarpConfig
+	// Origin of every config key, e.g. "warp.folderPath"
+	origins map[string]Origin
 }
 
-var configs *combinedConfigs
+// Snapshot in effect, replaced as a whole by Load and Reload
+var current atomic.Pointer[Snapshot]
 
 // Initialize the default configs
 func init() {
-	if App != nil {
-		return
+	app, warp := defaults()
+	current.Store(&Snapshot{App: app, Warp: warp, origins: defaultOrigins()})
+}
+
+// Configs in effect. Hold on to the snapshot when several values have to come from the same load.
+func Current() *Snapshot {
+	return current.Load()
+}
+
+// App config in effect
+func App() *AppConfig {
+	return current.Load().App
+}
+
+// Warp config in effect
+func Warp() *WarpConfig {
+	return current.Load().Warp
+}
+
+// Load all config layers over the built-in defaults, in order:
+// system file, user file, environment variables and command-line flags
+func Load() error {
+	snapshot, err := build()
+	if err != nil {
+		return err
+	}
+
+	current.Store(snapshot)
+	return nil
+}
+
+// Load all config layers again and swap them in only when they are valid,
+// otherwise the current configs are kept
+func Reload() error {
+	snapshot, err := build()
+	if err != nil {
+		return err
 	}
-	if Warp != nil {
-		return
+	if err = errors.Join(snapshot.App.Validate(), snapshot.Warp.Validate()); err != nil {
+		return fmt.Errorf("invalid config:\n%w", err)
 	}
 
-	warp := &WarpConfig{}
-	app := &AppConfig{}
+	current.Store(snapshot)
+	return nil
+}
 
-	app.InstallPath = "C:\\Program Files\\ezForce"
-	app.ExecName = "ezforce.exe"
-	app.LogFileName = "ezforce.log"
-	app.ConfigName = "ezforce.json"
-	app.ServiceName = "ezForce"
+// Build fresh configs from all layers, leaving the current configs untouched
+func build() (*Snapshot, error) {
+	app, warp := defaults()
+	loadedOrigins := defaultOrigins()
 
-	warp.FolderPath = "C:\\Program Files\\Cloudflare\\Cloudflare WARP"
-	warp.GUIExecName = "Cloudflare WARP.exe"
-	warp.SvcExecName = "warp-svc.exe"
-	warp.ServiceName = "CloudflareWARP"
+	// Config files have to be signed once a public key is trusted
+	key, err := TrustedKey()
+	if err != nil {
+		return nil, err
+	}
 
-	App = app
-	Warp = warp
+	for _, file := range Files() {
+		err := loadFile(file.Path, key, Origin{Layer: file.Layer, Source: file.Path}, app, warp, loadedOrigins)
+		// Deleting the signed system file must not fall back to the defaults
+		if errors.Is(err, os.ErrNotExist) && key != nil && file.Layer == LayerSystem {
+			return nil, fmt.Errorf("config files have to be signed, but the system config file '%s' is missing", file.Path)
+		}
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	configs = &combinedConfigs{
-		app:  App,
-		warp: Warp,
+	// Only the signed files may change the policy once a key is trusted
+	signed := key != nil
+	if err := applyEnv(app, warp, loadedOrigins, signed); err != nil {
+		return nil, err
+	}
+	if err := applyFlags(app, warp, loadedOrigins, signed); err != nil {
+		return nil, err
 	}
 
-	return
+	return &Snapshot{App: app, Warp: warp, origins: loadedOrigins}, nil
 }
 
-// Load external config file
-func Load(configPath string) error {
-	// Check if default configs are initialized
-	if App == nil {
-		return errors.New("app config not initialized")
+// Apply the 'app' and 'warp' sections of a config file over the given configs.
+// With a trusted key, the file is refused unless its signature matches.
+func loadFile(path string, key ed25519.PublicKey, origin Origin, app *AppConfig, warp *WarpConfig, origins map[string]Origin) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file:\n %w", err)
 	}
-	if Warp == nil {
-		return errors.New("warp config not initialized")
+
+	if key != nil {
+		if err = verifySignature(key, path, data); err != nil {
+			return fmt.Errorf("refusing config file '%s':\n %w", path, err)
+		}
 	}
 
-	configFile, err := os.Open(configPath)
+	// Rewriting a signed file would invalidate its signature
+	migrated, err := migrateFile(path, data, key == nil)
 	if err != nil {
-		return fmt.Errorf("failed to open config file: %w", err)
+		return fmt.Errorf("failed to migrate config file '%s'%s:\n %w", path, location(data, err), err)
 	}
-	defer configFile.Close()
 
-	jsonParser := json.NewDecoder(configFile)
-	if err = jsonParser.Decode(&configs); err != nil {
-		return fmt.Errorf("failed to parse config file: %w", err)
+	if err = apply(migrated, origin, app, warp, origins); err != nil {
+		return fmt.Errorf("failed to parse config file '%s'%s:\n %w", path, location(migrated, err), err)
+	}
+	return nil
+}
+
+// Apply the sections of a JSON config over the given configs, rejecting unknown keys
+func apply(data []byte, origin Origin, app *AppConfig, warp *WarpConfig, origins map[string]Origin) error {
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal(data, &sections); err != nil {
+		return err
+	}
+
+	for section, raw := range sections {
+		if section == versionKey {
+			continue
+		}
+		target, found := sectionTarget(section, app, warp)
+		if !found {
+			return &keyError{path: []string{section}, err: fmt.Errorf("unknown key '%s'", section)}
+		}
+
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &values); err != nil {
+			return &keyError{path: []string{section}, err: fmt.Errorf("invalid value of key '%s':\n %w", section, err)}
+		}
+
+		for key, value := range values {
+			field, name, found := fieldByKey(target, key)
+			if !found {
+				return &keyError{path: []string{section, key}, err: fmt.Errorf("unknown key '%s.%s'", section, key)}
+			}
+			if err := json.Unmarshal(value, field.Addr().Interface()); err != nil {
+				return &keyError{path: []string{section, key}, err: fmt.Errorf("invalid value of key '%s.%s':\n %w", section, key, err)}
+			}
+			origins[strings.ToLower(section)+"."+name] = origin
+		}
 	}
 
 	return nil
 }
+
+// Config struct of the given section
+func sectionTarget(section string, app *AppConfig, warp *WarpConfig) (any, bool) {
+	switch strings.ToLower(section) {
+	case "app":
+		return app, true
+	case "warp":
+		return warp, true
+	default:
+		return nil, false
+	}
+}
+
+// Find the struct field whose JSON name matches the key, ignoring case
+func fieldByKey(target any, key string) (reflect.Value, string, bool) {
+	v := reflect.ValueOf(target).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := jsonName(t.Field(i))
+		if strings.EqualFold(name, key) {
+			return v.Field(i), name, true
+		}
+	}
+	return reflect.Value{}, "", false
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	return name
+}
+
+// Error about a key of a config file, located in the file by its path of object keys
+type keyError struct {
+	path []string
+	err  error
+}
+
+func (e *keyError) Error() string { return e.err.Error() }
+func (e *keyError) Unwrap() error { return e.err }
+
+// Where in the JSON data the error was found, e.g. " at line 3", empty when unknown
+func location(data []byte, err error) string {
+	offset := int64(-1)
+	var syntaxErr *json.SyntaxError
+	var keyErr *keyError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &keyErr):
+		offset = keyOffset(json.NewDecoder(bytes.NewReader(data)), keyErr.path)
+	}
+	if offset < 0 || offset > int64(len(data)) {
+		return ""
+	}
+	return fmt.Sprintf(" at line %d", bytes.Count(data[:offset], []byte{'\n'})+1)
+}
+
+// Offset just after the key reached by following the path through nested objects,
+// -1 when it is not found
+func keyOffset(dec *json.Decoder, path []string) int64 {
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return -1
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return -1
+		}
+		key, _ := tok.(string)
+		if key == path[0] {
+			if len(path) == 1 {
+				return dec.InputOffset()
+			}
+			return keyOffset(dec, path[1:])
+		}
+		var skipped json.RawMessage
+		if err := dec.Decode(&skipped); err != nil {
+			return -1
+		}
+	}
+	return -1
+}
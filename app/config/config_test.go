@@ -0,0 +1,104 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Readers racing a reload always see configs from a single load
+func TestReloadPublishesSnapshot(t *testing.T) {
+	t.Setenv("EZFORCE_APP_CHECKINTERVAL", "45")
+	t.Setenv("EZFORCE_APP_INSTALLPATH", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	before := Current()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			snapshot := Current()
+			if snapshot.App == nil || snapshot.Warp == nil {
+				t.Error("snapshot without configs")
+				return
+			}
+			_ = App().CheckInterval
+			_ = OriginOf("app.checkInterval")
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		if err := Reload(); err != nil {
+			t.Fatalf("Reload() error = %v", err)
+		}
+	}
+	wg.Wait()
+
+	if App().CheckInterval != 45 {
+		t.Errorf("CheckInterval = %d, want 45", App().CheckInterval)
+	}
+	if OriginOf("app.checkInterval").Layer != LayerEnv {
+		t.Errorf("origin = %v, want the environment", OriginOf("app.checkInterval"))
+	}
+	if before.App.CheckInterval == 45 {
+		t.Error("Reload modified the previous snapshot")
+	}
+	current.Store(before)
+}
+
+func TestLoadFileReportsFileAndLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		// Parts of the error, besides the file path
+		want []string
+	}{
+		{"unknown section", "{\n  \"version\": 2,\n  \"network\": {}\n}\n", []string{"unknown key 'network'", "line 3"}},
+		{"unknown key", "{\n  \"version\": 2,\n  \"app\": {\n    \"logLevel\": \"info\",\n    \"colour\": true\n  }\n}\n", []string{"unknown key 'app.colour'", "line 5"}},
+		{"invalid value", "{\n  \"version\": 2,\n  \"warp\": {\n    \"restartDelays\": \"5s\"\n  }\n}\n", []string{"invalid value of key 'warp.restartDelays'", "line 4"}},
+		{"invalid section", "{\n  \"version\": 2,\n  \"app\": []\n}\n", []string{"invalid value of key 'app'", "line 3"}},
+		{"syntax error", "{\n  \"version\": 2,\n  \"app\": {\n    \"logLevel\": \"info\",\n  }\n}\n", []string{"line 5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "ezforce.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			app, warp := defaults()
+			err := loadFile(path, nil, Origin{Layer: LayerSystem, Source: path}, app, warp, defaultOrigins())
+			if err == nil {
+				t.Fatal("loadFile() accepted the config file")
+			}
+			for _, want := range append(tt.want, path) {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("loadFile() error = %v, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadFileAppliesKnownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ezforce.json")
+	content := `{"version": 2, "app": {"LOGLEVEL": "debug"}, "warp": {"familiesMode": "full"}}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	app, warp := defaults()
+	origins := defaultOrigins()
+	origin := Origin{Layer: LayerSystem, Source: path}
+	if err := loadFile(path, nil, origin, app, warp, origins); err != nil {
+		t.Fatalf("loadFile() error = %v", err)
+	}
+	if app.LogLevel != "debug" || warp.FamiliesMode != "full" {
+		t.Errorf("logLevel %q familiesMode %q, want the file's values", app.LogLevel, warp.FamiliesMode)
+	}
+	if origins["app.logLevel"] != origin || origins["app.installPath"].Layer != LayerDefault {
+		t.Errorf("origins = %v and %v, want the file and the default", origins["app.logLevel"], origins["app.installPath"])
+	}
+}
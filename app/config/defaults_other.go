@@ -0,0 +1,36 @@
+//go:build !windows
+
+package config
+
+// Create the built-in default configs
+func defaults() (*AppConfig, *WarpConfig) {
+	warp := &WarpConfig{}
+	app := &AppConfig{}
+
+	app.InstallPath = "/opt/ezforce"
+	app.ExecName = "ezforce"
+	app.LogFileName = "ezforce.log"
+	app.LogLevel = "debug"
+	app.LogFormat = "console"
+	app.LogMaxSize = 10
+	app.LogMaxAge = 7
+	app.LogMaxBackups = 5
+	app.LogBackend = ""
+	app.SyslogAddress = "udp://127.0.0.1:514"
+	app.ConfigName = "ezforce.json"
+	app.ServiceName = "ezforce"
+	app.CheckInterval = 30
+	app.Guardian = false
+
+	// Cloudflare's Linux packages install the client binaries into /usr/bin
+	warp.FolderPath = "/usr/bin"
+	warp.GUIExecName = "warp-taskbar"
+	warp.SvcExecName = "warp-svc"
+	warp.ServiceName = "warp-svc"
+	warp.RequiredMode = "warp"
+	warp.FamiliesMode = "off"
+	warp.RestartDelays = []int{5, 10, 30}
+	warp.RestartResetPeriod = 86400
+
+	return app, warp
+}
@@ -0,0 +1,33 @@
+package config
+
+// Create the built-in default configs
+func defaults() (*AppConfig, *WarpConfig) {
+	warp := &WarpConfig{}
+	app := &AppConfig{}
+
+	app.InstallPath = "C:\\Program Files\\ezForce"
+	app.ExecName = "ezforce.exe"
+	app.LogFileName = "ezforce.log"
+	app.LogLevel = "debug"
+	app.LogFormat = "console"
+	app.LogMaxSize = 10
+	app.LogMaxAge = 7
+	app.LogMaxBackups = 5
+	app.LogBackend = ""
+	app.SyslogAddress = "udp://127.0.0.1:514"
+	app.ConfigName = "ezforce.json"
+	app.ServiceName = "ezForce"
+	app.CheckInterval = 30
+	app.Guardian = false
+
+	warp.FolderPath = "C:\\Program Files\\Cloudflare\\Cloudflare WARP"
+	warp.GUIExecName = "Cloudflare WARP.exe"
+	warp.SvcExecName = "warp-svc.exe"
+	warp.ServiceName = "CloudflareWARP"
+	warp.RequiredMode = "warp"
+	warp.FamiliesMode = "off"
+	warp.RestartDelays = []int{5, 10, 30}
+	warp.RestartResetPeriod = 86400
+
+	return app, warp
+}
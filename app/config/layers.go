@@ -0,0 +1,260 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Source of config values, later layers override earlier ones
+type Layer int
+
+const (
+	LayerDefault Layer = iota
+	LayerSystem
+	LayerUser
+	LayerEnv
+	LayerFlag
+)
+
+func (l Layer) String() string {
+	switch l {
+	case LayerDefault:
+		return "default"
+	case LayerSystem:
+		return "system file"
+	case LayerUser:
+		return "user file"
+	case LayerEnv:
+		return "env"
+	case LayerFlag:
+		return "flag"
+	default:
+		return "unknown"
+	}
+}
+
+// Where the effective value of a config key was set
+type Origin struct {
+	Layer Layer
+	// File path, variable or flag name that set the value
+	Source string
+}
+
+func (o Origin) String() string {
+	if o.Source == "" {
+		return o.Layer.String()
+	}
+	return fmt.Sprintf("%v '%s'", o.Layer, o.Source)
+}
+
+// Prefix of the environment variables overriding config keys
+const EnvPrefix = "EZFORCE_"
+
+// Values of the config flags given on the command line
+var flagValues = map[string]string{}
+
+// Every config key in declaration order, e.g. "app.installPath"
+func Keys() []string {
+	var keys []string
+	for _, section := range []struct {
+		name string
+		typ  reflect.Type
+	}{
+		{"app", reflect.TypeFor[AppConfig]()},
+		{"warp", reflect.TypeFor[WarpConfig]()},
+	} {
+		for i := 0; i < section.typ.NumField(); i++ {
+			keys = append(keys, section.name+"."+jsonName(section.typ.Field(i)))
+		}
+	}
+	return keys
+}
+
+// Origin of the effective value of a config key
+func OriginOf(key string) Origin {
+	return Current().origins[key]
+}
+
+// Effective value of a config key
+func Value(key string) (any, error) {
+	snapshot := Current()
+	field, err := lookup(key, snapshot.App, snapshot.Warp)
+	if err != nil {
+		return nil, err
+	}
+	return field.Interface(), nil
+}
+
+// Environment variable overriding a config key, e.g. EZFORCE_WARP_FOLDERPATH
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// Register a command-line flag for every config key, e.g. -warp.folderPath
+func RegisterFlags(fs *flag.FlagSet) {
+	for _, key := range Keys() {
+		fs.Func(key, fmt.Sprintf("Override the '%s' config key", key), func(value string) error {
+			flagValues[key] = value
+			return nil
+		})
+	}
+}
+
+// Config file of a layer
+type File struct {
+	Layer Layer
+	Path  string
+}
+
+// Config files in the order they are applied
+func Files() []File {
+	files := []File{{Layer: LayerSystem, Path: SystemConfigPath()}}
+	if path := UserConfigPath(); path != "" {
+		files = append(files, File{Layer: LayerUser, Path: path})
+	}
+	return files
+}
+
+// Path of the system-wide config file next to the install path.
+// Only the defaults, environment and flags can move it, never a config file.
+func SystemConfigPath() string {
+	app, _ := defaults()
+	return filepath.Join(override("app.installPath", app.InstallPath), override("app.configName", app.ConfigName))
+}
+
+// Path of the per-user config file, empty when there is no user config directory
+func UserConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	app, _ := defaults()
+	return filepath.Join(dir, "ezForce", override("app.configName", app.ConfigName))
+}
+
+// Value of a key from the flag or environment layers, falling back to the given one.
+// Used to locate the config files before the layers are applied.
+func override(key string, fallback string) string {
+	if value, found := flagValues[key]; found {
+		return value
+	}
+	if value, found := os.LookupEnv(EnvName(key)); found {
+		return value
+	}
+	return fallback
+}
+
+// Keys the environment and flags may still set once config files have to be signed,
+// none of them weakens the enforcement
+var unsignedKeys = map[string]bool{
+	"app.logLevel":  true,
+	"app.logFormat": true,
+}
+
+func applyEnv(app *AppConfig, warp *WarpConfig, origins map[string]Origin, signed bool) error {
+	for _, key := range Keys() {
+		name := EnvName(key)
+		value, found := os.LookupEnv(name)
+		if !found {
+			continue
+		}
+		if signed && !unsignedKeys[key] {
+			return fmt.Errorf("refusing environment variable '%s', config files are signed so '%s' can only be set by them", name, key)
+		}
+		if err := set(key, value, app, warp); err != nil {
+			return fmt.Errorf("invalid value of environment variable '%s':\n %w", name, err)
+		}
+		origins[key] = Origin{Layer: LayerEnv, Source: name}
+	}
+	return nil
+}
+
+func applyFlags(app *AppConfig, warp *WarpConfig, origins map[string]Origin, signed bool) error {
+	for _, key := range Keys() {
+		value, found := flagValues[key]
+		if !found {
+			continue
+		}
+		if signed && !unsignedKeys[key] {
+			return fmt.Errorf("refusing flag '-%s', config files are signed so it can only be set by them", key)
+		}
+		if err := set(key, value, app, warp); err != nil {
+			return fmt.Errorf("invalid value of flag '-%s':\n %w", key, err)
+		}
+		origins[key] = Origin{Layer: LayerFlag, Source: "-" + key}
+	}
+	return nil
+}
+
+// Set a config key from its string representation
+func set(key string, value string, app *AppConfig, warp *WarpConfig) error {
+	field, err := lookup(key, app, warp)
+	if err != nil {
+		return err
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("'%s' is not a number", value)
+		}
+		field.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("'%s' is not a boolean", value)
+		}
+		field.SetBool(b)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.Int {
+			return fmt.Errorf("unsupported type of key '%s'", key)
+		}
+		// Comma separated list, e.g. "5,10,30"
+		var numbers []int
+		for _, part := range strings.Split(value, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return fmt.Errorf("'%s' is not a number", part)
+			}
+			numbers = append(numbers, n)
+		}
+		field.Set(reflect.ValueOf(numbers))
+	default:
+		return fmt.Errorf("unsupported type of key '%s'", key)
+	}
+	return nil
+}
+
+// Find the struct field of a "section.name" config key
+func lookup(key string, app *AppConfig, warp *WarpConfig) (reflect.Value, error) {
+	section, name, _ := strings.Cut(key, ".")
+	target, found := sectionTarget(section, app, warp)
+	if !found {
+		return reflect.Value{}, fmt.Errorf("unknown key '%s'", key)
+	}
+	field, _, found := fieldByKey(target, name)
+	if !found {
+		return reflect.Value{}, fmt.Errorf("unknown key '%s'", key)
+	}
+	return field, nil
+}
+
+func defaultOrigins() map[string]Origin {
+	origins := make(map[string]Origin)
+	for _, key := range Keys() {
+		origins[key] = Origin{Layer: LayerDefault}
+	}
+	return origins
+}
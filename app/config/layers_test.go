@@ -0,0 +1,148 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Write a config file setting app.checkInterval, creating its directory
+func writeLayer(t *testing.T, path string, checkInterval string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := `{"version": 2, "app": {"checkInterval": ` + checkInterval + `}}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Isolate the config files and flags of the test
+func useLayers(t *testing.T) {
+	t.Helper()
+	t.Setenv("EZFORCE_APP_INSTALLPATH", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	saved := flagValues
+	flagValues = map[string]string{}
+	t.Cleanup(func() { flagValues = saved })
+}
+
+func TestLayerPrecedence(t *testing.T) {
+	tests := []struct {
+		name                     string
+		system, user, env, flags string
+		// Expected check interval, 0 for the default
+		want  int
+		layer Layer
+	}{
+		{name: "default", layer: LayerDefault},
+		{name: "system file", system: "10", want: 10, layer: LayerSystem},
+		{name: "user over system", system: "10", user: "20", want: 20, layer: LayerUser},
+		{name: "env over files", system: "10", user: "20", env: "30", want: 30, layer: LayerEnv},
+		{name: "flag over all", system: "10", user: "20", env: "30", flags: "40", want: 40, layer: LayerFlag},
+		{name: "flag over default", flags: "40", want: 40, layer: LayerFlag},
+		{name: "env over system", system: "10", env: "30", want: 30, layer: LayerEnv},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useLayers(t)
+			if tt.system != "" {
+				writeLayer(t, SystemConfigPath(), tt.system)
+			}
+			if tt.user != "" {
+				writeLayer(t, UserConfigPath(), tt.user)
+			}
+			if tt.env != "" {
+				t.Setenv("EZFORCE_APP_CHECKINTERVAL", tt.env)
+			}
+			if tt.flags != "" {
+				flagValues["app.checkInterval"] = tt.flags
+			}
+
+			want := tt.want
+			if want == 0 {
+				defaultApp, _ := defaults()
+				want = defaultApp.CheckInterval
+			}
+
+			snapshot, err := build()
+			if err != nil {
+				t.Fatalf("build() error = %v", err)
+			}
+			if snapshot.App.CheckInterval != want {
+				t.Errorf("checkInterval = %d, want %d", snapshot.App.CheckInterval, want)
+			}
+			if origin := snapshot.origins["app.checkInterval"]; origin.Layer != tt.layer {
+				t.Errorf("origin = %v, want %v", origin, tt.layer)
+			}
+			// Keys no layer sets keep their default
+			if origin := snapshot.origins["warp.familiesMode"]; origin.Layer != LayerDefault {
+				t.Errorf("origin of warp.familiesMode = %v, want the default", origin)
+			}
+		})
+	}
+}
+
+func TestRegisterFlags(t *testing.T) {
+	useLayers(t)
+	fs := flag.NewFlagSet("ezforce", flag.ContinueOnError)
+	RegisterFlags(fs)
+	if err := fs.Parse([]string{"-warp.familiesMode", "full", "status"}); err != nil {
+		t.Fatal(err)
+	}
+	if flagValues["warp.familiesMode"] != "full" || fs.Arg(0) != "status" {
+		t.Errorf("flag values = %v, args = %v, want the override before the command", flagValues, fs.Args())
+	}
+}
+
+func TestShowOrigin(t *testing.T) {
+	useLayers(t)
+	writeLayer(t, SystemConfigPath(), "10")
+	t.Setenv("EZFORCE_WARP_FAMILIESMODE", "full")
+	flagValues["app.logLevel"] = "debug"
+
+	snapshot, err := build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := current.Load()
+	current.Store(snapshot)
+	t.Cleanup(func() { current.Store(before) })
+
+	var out bytes.Buffer
+	if err := Show(&out, true); err != nil {
+		t.Fatal(err)
+	}
+	lines := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		key, rest, _ := strings.Cut(line, " ")
+		lines[key] = strings.Join(strings.Fields(rest), " ")
+	}
+	if len(lines) != len(Keys()) {
+		t.Errorf("printed %d keys, want %d", len(lines), len(Keys()))
+	}
+
+	want := map[string]string{
+		"app.checkInterval": "10 system file '" + SystemConfigPath() + "'",
+		"warp.familiesMode": "full env 'EZFORCE_WARP_FAMILIESMODE'",
+		"app.logLevel":      "debug flag '-app.logLevel'",
+		"warp.serviceName":  snapshot.Warp.ServiceName + " default",
+	}
+	for key, line := range want {
+		if lines[key] != line {
+			t.Errorf("%s = %q, want %q", key, lines[key], line)
+		}
+	}
+
+	out.Reset()
+	if err := Show(&out, false); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "default") || strings.Contains(out.String(), "EZFORCE_") {
+		t.Errorf("Show() without origins printed them:\n%s", out.String())
+	}
+}
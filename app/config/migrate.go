@@ -0,0 +1,137 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Version of the config file format written by this build
+const CurrentVersion = 2
+
+// Top-level key holding the config file format version, files without it are version 1
+const versionKey = "version"
+
+// Step upgrading a config file to the next version
+type migration struct {
+	// Version the file has after the migration
+	to          int
+	description string
+	apply       func(doc map[string]any) error
+}
+
+// Migrations in version order
+var migrations = []migration{
+	{to: 2, description: "rename keys to their camelCase names", apply: migrateCamelCaseKeys},
+}
+
+// Upgrade the config file to the current version. When writeBack is set, the
+// original is copied to '<path>.bak' before the migrated file is written back.
+func migrateFile(path string, data []byte, writeBack bool) ([]byte, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	version, err := fileVersion(doc)
+	if err != nil {
+		return nil, err
+	}
+	if version == CurrentVersion {
+		return data, nil
+	}
+	if version > CurrentVersion {
+		return nil, fmt.Errorf("config version %d is newer than the supported version %d", version, CurrentVersion)
+	}
+
+	for _, m := range migrations {
+		if m.to <= version {
+			continue
+		}
+		log.Info().Msgf("Migrating config file '%s' from version %d to %d: %s", path, version, m.to, m.description)
+		if err := m.apply(doc); err != nil {
+			return nil, fmt.Errorf("could not migrate config from version %d to %d:\n %w", version, m.to, err)
+		}
+		version = m.to
+		doc[versionKey] = version
+	}
+
+	migrated, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("could not encode migrated config:\n %w", err)
+	}
+	migrated = append(migrated, '\n')
+
+	if !writeBack {
+		log.Warn().Msgf("Signed config file '%s' migrated in memory only, migrate and sign it again to keep it current", path)
+		return migrated, nil
+	}
+
+	// A file that cannot be written back is still used in its migrated form
+	if err := writeMigrated(path, data, migrated); err != nil {
+		log.Warn().Msgf("Could not write migrated config file '%s', using it in memory only:\n %v", path, err)
+	}
+
+	return migrated, nil
+}
+
+func writeMigrated(path string, original []byte, migrated []byte) error {
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	backupPath := path + ".bak"
+	if err := os.WriteFile(backupPath, original, perm); err != nil {
+		return fmt.Errorf("could not write backup '%s':\n %w", backupPath, err)
+	}
+	log.Info().Msgf("Backed up config file '%s' to '%s'", path, backupPath)
+
+	if err := os.WriteFile(path, migrated, perm); err != nil {
+		return err
+	}
+	log.Info().Msgf("Config file '%s' migrated to version %d", path, CurrentVersion)
+	return nil
+}
+
+func fileVersion(doc map[string]any) (int, error) {
+	raw, found := doc[versionKey]
+	if !found {
+		return 1, nil
+	}
+	version, ok := raw.(float64)
+	if !ok || version != float64(int(version)) || version < 1 {
+		return 0, fmt.Errorf("invalid value of key '%s': %v", versionKey, raw)
+	}
+	return int(version), nil
+}
+
+// Version 1 used PascalCase keys, e.g. "InstallPath" instead of "installPath"
+func migrateCamelCaseKeys(doc map[string]any) error {
+	app, warp := defaults()
+	for section, raw := range doc {
+		if section == versionKey {
+			continue
+		}
+		target, found := sectionTarget(section, app, warp)
+		values, isObject := raw.(map[string]any)
+		if !found || !isObject {
+			continue
+		}
+
+		renamed := make(map[string]any, len(values))
+		for key, value := range values {
+			if _, name, found := fieldByKey(target, key); found {
+				key = name
+			}
+			renamed[key] = value
+		}
+
+		delete(doc, section)
+		doc[strings.ToLower(section)] = renamed
+	}
+	return nil
+}
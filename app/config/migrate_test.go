@@ -0,0 +1,123 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Version 1 config with PascalCase keys
+const v1Config = `{"App": {"LogLevel": "debug"}, "Warp": {"RequiredMode": "warp"}}`
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ezforce.json")
+	if err := os.WriteFile(path, []byte(content), 0640); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Check that the file still has the given content and was not backed up
+func checkUntouched(t *testing.T, path string, content string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != content {
+		t.Errorf("config file = %q, %v, want it untouched", data, err)
+	}
+	if _, err := os.Stat(path + ".bak"); !os.IsNotExist(err) {
+		t.Errorf("backup written, stat error = %v", err)
+	}
+}
+
+func TestMigrateWritesBackup(t *testing.T) {
+	path := writeConfig(t, v1Config)
+
+	migrated, err := migrateFile(path, []byte(v1Config), true)
+	if err != nil {
+		t.Fatalf("migrateFile() error = %v", err)
+	}
+
+	backup, err := os.ReadFile(path + ".bak")
+	if err != nil || string(backup) != v1Config {
+		t.Errorf("backup = %q, %v, want the original", backup, err)
+	}
+	if info, err := os.Stat(path + ".bak"); err == nil && info.Mode().Perm() != 0640 {
+		t.Errorf("backup permissions = %v, want those of the original", info.Mode().Perm())
+	}
+	written, err := os.ReadFile(path)
+	if err != nil || !bytes.Equal(written, migrated) {
+		t.Errorf("config file = %q, %v, want the migrated config %q", written, err, migrated)
+	}
+}
+
+func TestMigrateBumpsVersion(t *testing.T) {
+	path := writeConfig(t, v1Config)
+	migrated, err := migrateFile(path, []byte(v1Config), true)
+	if err != nil {
+		t.Fatalf("migrateFile() error = %v", err)
+	}
+
+	var doc struct {
+		Version int
+		App     map[string]any `json:"app"`
+		Warp    map[string]any `json:"warp"`
+	}
+	if err := json.Unmarshal(migrated, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Version != CurrentVersion {
+		t.Errorf("version = %d, want %d", doc.Version, CurrentVersion)
+	}
+	if doc.App["logLevel"] != "debug" || doc.Warp["requiredMode"] != "warp" {
+		t.Errorf("migrated config = %s, want the camelCase keys", migrated)
+	}
+
+	// A current file is left as it is
+	again, err := migrateFile(path, migrated, true)
+	if err != nil || !bytes.Equal(again, migrated) {
+		t.Errorf("migrateFile() of a current file = %q, %v, want it unchanged", again, err)
+	}
+}
+
+func TestMigrateRejectsVersion(t *testing.T) {
+	tests := map[string]string{
+		"future":   `{"version": 3, "app": {}}`,
+		"fraction": `{"version": 1.5, "app": {}}`,
+		"zero":     `{"version": 0, "app": {}}`,
+		"string":   `{"version": "2", "app": {}}`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := writeConfig(t, content)
+			if _, err := migrateFile(path, []byte(content), true); err == nil {
+				t.Error("migrateFile() accepted the version")
+			}
+			checkUntouched(t, path, content)
+		})
+	}
+}
+
+func TestFailedMigrationLeavesFile(t *testing.T) {
+	original := migrations
+	t.Cleanup(func() { migrations = original })
+	migrations = append([]migration(nil), migrations...)
+	migrations[0].apply = func(doc map[string]any) error { return errors.New("broken") }
+
+	path := writeConfig(t, v1Config)
+	if _, err := migrateFile(path, []byte(v1Config), true); err == nil {
+		t.Fatal("migrateFile() succeeded with a failing migration")
+	}
+	checkUntouched(t, path, v1Config)
+}
+
+func TestMigrateSignedInMemory(t *testing.T) {
+	path := writeConfig(t, v1Config)
+	if _, err := migrateFile(path, []byte(v1Config), false); err != nil {
+		t.Fatalf("migrateFile() error = %v", err)
+	}
+	checkUntouched(t, path, v1Config)
+}
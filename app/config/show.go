@@ -0,0 +1,24 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// Print the effective value of every config key, optionally with the layer that set it
+func Show(w io.Writer, withOrigin bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, key := range Keys() {
+		value, err := Value(key)
+		if err != nil {
+			return err
+		}
+		if withOrigin {
+			fmt.Fprintf(tw, "%s\t%v\t%v\n", key, value, OriginOf(key))
+		} else {
+			fmt.Fprintf(tw, "%s\t%v\n", key, value)
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,189 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Base64 Ed25519 public key trusted to sign config files, set at build time with
+// -ldflags "-X github.com/ezydark/ezforce/app/config.PublicKey=<key>".
+// When neither this nor a pinned key file exists, config files are not verified.
+var PublicKey string
+
+// Name of the public key file pinned next to the install path
+const PublicKeyName = "ezforce.pub"
+
+// Name of the file recording at install time which pinned key config files have to be signed with
+const SigningRequiredName = "ezforce.pub.required"
+
+// Extension of the detached signature written next to a signed config file
+const SignatureExt = ".sig"
+
+// Path of the public key pinned at install time. It is always next to the built-in
+// install path, the environment and flags could otherwise hide it.
+func PublicKeyPath() string {
+	app, _ := defaults()
+	return filepath.Join(app.InstallPath, PublicKeyName)
+}
+
+// Public key config files have to be signed with, nil when signing is not enforced
+func TrustedKey() (ed25519.PublicKey, error) {
+	if PublicKey != "" {
+		key, err := parsePublicKey([]byte(PublicKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid compiled-in public key:\n %w", err)
+		}
+		return key, nil
+	}
+
+	app, _ := defaults()
+	return pinnedKey(app.InstallPath)
+}
+
+// Public key pinned in the given directory. Once signing was required at install
+// time, a missing or replaced key fails instead of turning the verification off.
+func pinnedKey(dir string) (ed25519.PublicKey, error) {
+	requiredPath := filepath.Join(dir, SigningRequiredName)
+	required, err := os.ReadFile(requiredPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("could not read '%s':\n %w", requiredPath, err)
+	}
+
+	path := filepath.Join(dir, PublicKeyName)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if required != nil {
+			return nil, fmt.Errorf("config files have to be signed since install, but the public key '%s' is missing", path)
+		}
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read public key '%s':\n %w", path, err)
+	}
+	key, err := parsePublicKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key '%s':\n %w", path, err)
+	}
+
+	if required != nil && strings.TrimSpace(string(required)) != base64.StdEncoding.EncodeToString(key) {
+		return nil, fmt.Errorf("public key '%s' was replaced since install", path)
+	}
+	return key, nil
+}
+
+// Record that config files have to be signed with the pinned public key, so that
+// removing or replacing it later fails the config load. Does nothing without a pinned key.
+func RequireSigning() error {
+	if PublicKey != "" {
+		return nil
+	}
+	app, _ := defaults()
+	return requireSigning(app.InstallPath)
+}
+
+func requireSigning(dir string) error {
+	key, err := pinnedKey(dir)
+	if err != nil || key == nil {
+		return err
+	}
+
+	path := filepath.Join(dir, SigningRequiredName)
+	err = os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0644)
+	if err != nil {
+		return fmt.Errorf("could not write '%s':\n %w", path, err)
+	}
+	return nil
+}
+
+// Check the detached signature of a config file's content
+func verifySignature(key ed25519.PublicKey, path string, data []byte) error {
+	sigPath := path + SignatureExt
+	encoded, err := os.ReadFile(sigPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("config file is not signed, missing '%s'", sigPath)
+	}
+	if err != nil {
+		return fmt.Errorf("could not read signature '%s':\n %w", sigPath, err)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil {
+		return fmt.Errorf("invalid signature '%s':\n %w", sigPath, err)
+	}
+	if !ed25519.Verify(key, data, sig) {
+		return fmt.Errorf("signature '%s' does not match the config file, it was modified after signing", sigPath)
+	}
+	return nil
+}
+
+// Sign a config file with the admin's PEM encoded (PKCS #8) Ed25519 private key,
+// writing the signature to '<path>.sig'. Such a key can be created with
+// 'openssl genpkey -algorithm ed25519' and its public half pinned as ezforce.pub.
+func Sign(path string, privateKeyPath string) error {
+	keyData, err := os.ReadFile(privateKeyPath)
+	if err != nil {
+		return fmt.Errorf("could not read private key:\n %w", err)
+	}
+	key, err := parsePrivateKey(keyData)
+	if err != nil {
+		return fmt.Errorf("invalid private key '%s':\n %w", privateKeyPath, err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read config file:\n %w", err)
+	}
+
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(key, data))
+	if err = os.WriteFile(path+SignatureExt, []byte(sig+"\n"), 0644); err != nil {
+		return fmt.Errorf("could not write signature:\n %w", err)
+	}
+	return nil
+}
+
+// Accepts a PEM encoded PKIX key or the base64 of the raw 32 byte key
+func parsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := parsed.(ed25519.PublicKey)
+		if !ok {
+			return nil, errors.New("not an Ed25519 public key")
+		}
+		return key, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key must be %d bytes, got %d", ed25519.PublicKeySize, len(raw))
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+func parsePrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an Ed25519 private key")
+	}
+	return key, nil
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func pinKey(t *testing.T, dir string) ed25519.PublicKey {
+	t.Helper()
+	key, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded := base64.StdEncoding.EncodeToString(key)
+	if err := os.WriteFile(filepath.Join(dir, PublicKeyName), []byte(encoded+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestPinnedKeyWithoutRequirement(t *testing.T) {
+	dir := t.TempDir()
+	key, err := pinnedKey(dir)
+	if err != nil || key != nil {
+		t.Fatalf("pinnedKey() = %v, %v, want no key and no error", key, err)
+	}
+
+	want := pinKey(t, dir)
+	key, err = pinnedKey(dir)
+	if err != nil || !key.Equal(want) {
+		t.Fatalf("pinnedKey() = %v, %v, want the pinned key", key, err)
+	}
+}
+
+func TestPinnedKeyRequiredAfterInstall(t *testing.T) {
+	dir := t.TempDir()
+	want := pinKey(t, dir)
+	if err := requireSigning(dir); err != nil {
+		t.Fatalf("requireSigning() error = %v", err)
+	}
+
+	key, err := pinnedKey(dir)
+	if err != nil || !key.Equal(want) {
+		t.Fatalf("pinnedKey() = %v, %v, want the pinned key", key, err)
+	}
+
+	// A replaced key is refused
+	pinKey(t, dir)
+	if _, err := pinnedKey(dir); err == nil || !strings.Contains(err.Error(), "replaced") {
+		t.Errorf("pinnedKey() error = %v, want the replaced key refused", err)
+	}
+
+	// A removed key does not turn the verification off
+	if err := os.Remove(filepath.Join(dir, PublicKeyName)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pinnedKey(dir); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("pinnedKey() error = %v, want the missing key reported", err)
+	}
+}
+
+func TestRequireSigningWithoutKey(t *testing.T) {
+	dir := t.TempDir()
+	if err := requireSigning(dir); err != nil {
+		t.Fatalf("requireSigning() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, SigningRequiredName)); !os.IsNotExist(err) {
+		t.Errorf("signing required without a pinned key, stat error = %v", err)
+	}
+}
+
+func TestSignedConfigRefusesPolicyOverrides(t *testing.T) {
+	app, warp := defaults()
+	origins := defaultOrigins()
+
+	t.Setenv("EZFORCE_WARP_REQUIREDMODE", "")
+	if err := applyEnv(app, warp, origins, true); err == nil {
+		t.Error("applyEnv() accepted a policy key with signed config files")
+	}
+	if err := applyEnv(app, warp, origins, false); err != nil || warp.RequiredMode != "" {
+		t.Errorf("applyEnv() = %v, requiredMode %q, want the override applied without signing", err, warp.RequiredMode)
+	}
+
+	os.Unsetenv("EZFORCE_WARP_REQUIREDMODE")
+	t.Setenv("EZFORCE_APP_LOGLEVEL", "warn")
+	if err := applyEnv(app, warp, origins, true); err != nil || app.LogLevel != "warn" {
+		t.Errorf("applyEnv() = %v, logLevel %q, want the log level still overridable", err, app.LogLevel)
+	}
+
+	flagValues["warp.familiesMode"] = "off"
+	t.Cleanup(func() { delete(flagValues, "warp.familiesMode") })
+	if err := applyFlags(app, warp, origins, true); err == nil {
+		t.Error("applyFlags() accepted a policy key with signed config files")
+	}
+}
+
+func TestSignedConfigRequiresSystemFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("EZFORCE_APP_INSTALLPATH", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", dir)
+
+	if _, err := build(); err != nil {
+		t.Fatalf("build() error = %v, want the defaults without config files and signing", err)
+	}
+
+	key, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKey := PublicKey
+	PublicKey = base64.StdEncoding.EncodeToString(key)
+	t.Cleanup(func() { PublicKey = oldKey })
+
+	_, err = build()
+	if err == nil || !strings.Contains(err.Error(), SystemConfigPath()) || !strings.Contains(err.Error(), "missing") {
+		t.Fatalf("build() error = %v, want the missing system config file reported", err)
+	}
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Accepted values of WarpConfig.RequiredMode, empty disables the mode enforcement
+var WarpModes = []string{"", "warp", "doh", "dot", "warp+doh", "warp+dot", "proxy", "tunnel_only"}
+
+// Accepted values of WarpConfig.FamiliesMode
+var FamiliesModes = []string{"off", "malware", "full"}
+
+// Allowed range of AppConfig.CheckInterval, in seconds
+const (
+	MinCheckInterval = 5
+	MaxCheckInterval = 3600
+)
+
+// Accepted values of AppConfig.LogLevel
+var LogLevels = []string{"trace", "debug", "info", "warn", "error"}
+
+// Accepted values of AppConfig.LogFormat
+var LogFormats = []string{"console", "json", "logfmt"}
+
+// Accepted values of AppConfig.LogBackend, empty disables it
+var LogBackends = []string{"", "journald", "syslog"}
+
+// Accepted networks of AppConfig.SyslogAddress
+var SyslogNetworks = []string{"udp", "tcp", "unix", "unixgram"}
+
+// Allowed ranges of the AppConfig log rotation
+const (
+	MaxLogMaxSize    = 1024
+	MaxLogMaxAge     = 365
+	MaxLogMaxBackups = 100
+)
+
+// Allowed ranges of the WarpConfig restart policy, in seconds
+const (
+	MaxRestartDelay       = 3600
+	MaxRestartResetPeriod = 7 * 24 * 3600
+)
+
+// Check the current configs, returning every problem found joined into one error
+func Validate() error {
+	snapshot := Current()
+	return errors.Join(snapshot.App.Validate(), snapshot.Warp.Validate())
+}
+
+func (c *AppConfig) Validate() error {
+	var errs []error
+	errs = append(errs, checkAbsPath("app.installPath", c.InstallPath))
+	errs = append(errs, checkFileName("app.execName", c.ExecName))
+	errs = append(errs, checkFileName("app.logFileName", c.LogFileName))
+	errs = append(errs, checkEnum("app.logLevel", c.LogLevel, LogLevels))
+	errs = append(errs, checkEnum("app.logFormat", c.LogFormat, LogFormats))
+	errs = append(errs, checkRange("app.logMaxSize", c.LogMaxSize, 1, MaxLogMaxSize))
+	errs = append(errs, checkRange("app.logMaxAge", c.LogMaxAge, 1, MaxLogMaxAge))
+	errs = append(errs, checkRange("app.logMaxBackups", c.LogMaxBackups, 0, MaxLogMaxBackups))
+	errs = append(errs, checkEnum("app.logBackend", c.LogBackend, LogBackends))
+	if c.LogBackend == "syslog" {
+		errs = append(errs, checkSyslogAddress("app.syslogAddress", c.SyslogAddress))
+	}
+	errs = append(errs, checkFileName("app.configName", c.ConfigName))
+	errs = append(errs, checkName("app.serviceName", c.ServiceName))
+	errs = append(errs, checkRange("app.checkInterval", c.CheckInterval, MinCheckInterval, MaxCheckInterval))
+	return errors.Join(errs...)
+}
+
+func (c *WarpConfig) Validate() error {
+	var errs []error
+	errs = append(errs, checkAbsPath("warp.folderPath", c.FolderPath))
+	errs = append(errs, checkFileName("warp.guiExecName", c.GUIExecName))
+	errs = append(errs, checkFileName("warp.svcExecName", c.SvcExecName))
+	errs = append(errs, checkName("warp.serviceName", c.ServiceName))
+	errs = append(errs, checkEnum("warp.requiredMode", c.RequiredMode, WarpModes))
+	errs = append(errs, checkEnum("warp.familiesMode", c.FamiliesMode, FamiliesModes))
+	for i, delay := range c.RestartDelays {
+		errs = append(errs, checkRange(fmt.Sprintf("warp.restartDelays[%d]", i), delay, 0, MaxRestartDelay))
+	}
+	errs = append(errs, checkRange("warp.restartResetPeriod", c.RestartResetPeriod, 0, MaxRestartResetPeriod))
+	return errors.Join(errs...)
+}
+
+func checkName(key string, value string) error {
+	if strings.TrimSpace(value) == "" {
+		return fmt.Errorf("%s: must not be empty", key)
+	}
+	return nil
+}
+
+func checkFileName(key string, value string) error {
+	if err := checkName(key, value); err != nil {
+		return err
+	}
+	if strings.ContainsAny(value, `/\`) {
+		return fmt.Errorf("%s: '%s' must be a file name, not a path", key, value)
+	}
+	return nil
+}
+
+func checkAbsPath(key string, value string) error {
+	if err := checkName(key, value); err != nil {
+		return err
+	}
+	if !filepath.IsAbs(value) {
+		return fmt.Errorf("%s: '%s' must be an absolute path", key, value)
+	}
+	return nil
+}
+
+func checkRange(key string, value int, min int, max int) error {
+	if value < min || value > max {
+		return fmt.Errorf("%s: %d must be between %d and %d", key, value, min, max)
+	}
+	return nil
+}
+
+func checkSyslogAddress(key string, value string) error {
+	network, address, found := strings.Cut(value, "://")
+	if !found || address == "" {
+		return fmt.Errorf("%s: '%s' must look like 'udp://host:port'", key, value)
+	}
+	return checkEnum(key+" network", network, SyslogNetworks)
+}
+
+func checkEnum(key string, value string, allowed []string) error {
+	if !slices.Contains(allowed, value) {
+		return fmt.Errorf("%s: '%s' must be one of %q", key, value, allowed)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDefaultsValid(t *testing.T) {
+	app, warp := defaults()
+	if err := app.Validate(); err != nil {
+		t.Errorf("default app config invalid:\n%v", err)
+	}
+	if err := warp.Validate(); err != nil {
+		t.Errorf("default warp config invalid:\n%v", err)
+	}
+}
+
+func TestAppValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *AppConfig)
+		// Key reported by the error, empty when the config is valid
+		key string
+	}{
+		{"relative install path", func(c *AppConfig) { c.InstallPath = "ezforce" }, "app.installPath"},
+		{"exec name with path", func(c *AppConfig) { c.ExecName = "bin/ezforce" }, "app.execName"},
+		{"empty log file name", func(c *AppConfig) { c.LogFileName = " " }, "app.logFileName"},
+		{"unknown log level", func(c *AppConfig) { c.LogLevel = "verbose" }, "app.logLevel"},
+		{"unknown log format", func(c *AppConfig) { c.LogFormat = "xml" }, "app.logFormat"},
+		{"log size zero", func(c *AppConfig) { c.LogMaxSize = 0 }, "app.logMaxSize"},
+		{"log age too long", func(c *AppConfig) { c.LogMaxAge = MaxLogMaxAge + 1 }, "app.logMaxAge"},
+		{"negative backups", func(c *AppConfig) { c.LogMaxBackups = -1 }, "app.logMaxBackups"},
+		{"no backups", func(c *AppConfig) { c.LogMaxBackups = 0 }, ""},
+		{"unknown backend", func(c *AppConfig) { c.LogBackend = "eventlog" }, "app.logBackend"},
+		{"syslog without address", func(c *AppConfig) { c.LogBackend, c.SyslogAddress = "syslog", "" }, "app.syslogAddress"},
+		{"syslog unknown network", func(c *AppConfig) { c.LogBackend, c.SyslogAddress = "syslog", "http://host:514" }, "app.syslogAddress network"},
+		{"syslog over udp", func(c *AppConfig) { c.LogBackend, c.SyslogAddress = "syslog", "udp://127.0.0.1:514" }, ""},
+		{"empty service name", func(c *AppConfig) { c.ServiceName = "" }, "app.serviceName"},
+		{"check interval too short", func(c *AppConfig) { c.CheckInterval = MinCheckInterval - 1 }, "app.checkInterval"},
+		{"check interval too long", func(c *AppConfig) { c.CheckInterval = MaxCheckInterval + 1 }, "app.checkInterval"},
+		{"check interval at the bounds", func(c *AppConfig) { c.CheckInterval = MaxCheckInterval }, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := defaults()
+			tt.change(app)
+			checkValidation(t, app.Validate(), tt.key)
+		})
+	}
+}
+
+func TestWarpValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *WarpConfig)
+		key    string
+	}{
+		{"relative folder", func(c *WarpConfig) { c.FolderPath = "Cloudflare" }, "warp.folderPath"},
+		{"unknown mode", func(c *WarpConfig) { c.RequiredMode = "vpn" }, "warp.requiredMode"},
+		{"mode not enforced", func(c *WarpConfig) { c.RequiredMode = "" }, ""},
+		{"unknown families mode", func(c *WarpConfig) { c.FamiliesMode = "adult" }, "warp.familiesMode"},
+		{"negative restart delay", func(c *WarpConfig) { c.RestartDelays = []int{5, -1} }, "warp.restartDelays[1]"},
+		{"restart delay too long", func(c *WarpConfig) { c.RestartDelays = []int{MaxRestartDelay + 1} }, "warp.restartDelays[0]"},
+		// No restart on failure, which every recovery backend has to report back as set
+		{"no restart delays", func(c *WarpConfig) { c.RestartDelays = nil }, ""},
+		{"reset period too long", func(c *WarpConfig) { c.RestartResetPeriod = MaxRestartResetPeriod + 1 }, "warp.restartResetPeriod"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, warp := defaults()
+			tt.change(warp)
+			checkValidation(t, warp.Validate(), tt.key)
+		})
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	app, _ := defaults()
+	app.LogLevel, app.CheckInterval = "verbose", 0
+	err := app.Validate()
+	if err == nil || !strings.Contains(err.Error(), "app.logLevel") || !strings.Contains(err.Error(), "app.checkInterval") {
+		t.Errorf("Validate() = %v, want both problems reported", err)
+	}
+}
+
+func checkValidation(t *testing.T, err error, key string) {
+	t.Helper()
+	if key == "" {
+		if err != nil {
+			t.Errorf("Validate() = %v, want a valid config", err)
+		}
+		return
+	}
+	if err == nil || !strings.HasPrefix(err.Error(), key+":") {
+		t.Errorf("Validate() = %v, want a problem with %s", err, key)
+	}
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// Poll the config files and send on the returned channel whenever one of them
+// is created, modified or removed. The channel is closed once ctx is done.
+func Watch(ctx context.Context, interval time.Duration) <-chan struct{} {
+	changes := make(chan struct{}, 1)
+
+	go func() {
+		defer close(changes)
+
+		last := fileStates()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				current := fileStates()
+				if current == last {
+					continue
+				}
+				last = current
+
+				// Drop the change when the previous one was not consumed yet
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	return changes
+}
+
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+// State of the system and user config files and their signatures
+func fileStates() [4]fileState {
+	var states [4]fileState
+	var paths []string
+	for _, file := range Files() {
+		paths = append(paths, file.Path, file.Path+SignatureExt)
+	}
+
+	for i, path := range paths {
+		if i >= len(states) {
+			break
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		states[i] = fileState{exists: true, size: info.Size(), modTime: info.ModTime()}
+	}
+	return states
+}
@@ -0,0 +1,280 @@
+package enforce
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ezydark/ezforce/app/config"
+	"github.com/ezydark/ezforce/libs/events"
+	"github.com/ezydark/ezforce/libs/warp"
+	"github.com/ezydark/ezforce/libs/warp/serv"
+	"github.com/rs/zerolog/log"
+)
+
+// Name of the file holding the result of the last pass, next to the install path
+const StatusFileName = "status.json"
+
+// Name of the file holding the event counters in the Prometheus text format, next to the install path
+const MetricsFileName = "metrics.prom"
+
+// Counters of the events emitted by the passes, written after each pass once registered as a sink
+var Metrics = events.NewMetrics()
+
+// Enforcement step, shared with the events the Ensure* functions emit
+type Step = events.Step
+
+const (
+	StepInstalled     = events.StepInstalled
+	StepServiceConfig = events.StepServiceConfig
+	StepEnabled       = events.StepEnabled
+	StepRunning       = events.StepRunning
+	StepRecovery      = events.StepRecovery
+	StepConnected     = events.StepConnected
+	StepMode          = events.StepMode
+	StepFamilies      = events.StepFamilies
+)
+
+// Outcome of a single step
+type StepResult struct {
+	Step     Step          `json:"step"`
+	OK       bool          `json:"ok"`
+	Message  string        `json:"message"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Outcome of a whole pass, which stops at the first failing core step
+type Result struct {
+	Time     time.Time     `json:"time"`
+	Duration time.Duration `json:"duration"`
+	OK       bool          `json:"ok"`
+	Steps    []StepResult  `json:"steps"`
+}
+
+// The step that stopped the pass, otherwise the last failed optional step,
+// nil when the pass succeeded
+func (r *Result) Failed() *StepResult {
+	for i := len(r.Steps) - 1; i >= 0; i-- {
+		if !r.Steps[i].OK {
+			return &r.Steps[i]
+		}
+	}
+	return nil
+}
+
+// Runs the enforcement pipeline, keeping the Warp service open between passes
+type Enforcer struct {
+	Serv *serv.WarpServ
+}
+
+func New() *Enforcer {
+	return &Enforcer{}
+}
+
+func (e *Enforcer) Close() error {
+	if e.Serv == nil {
+		return nil
+	}
+	err := e.Serv.Close()
+	e.Serv = nil
+	return err
+}
+
+type step struct {
+	name Step
+	run  func(ctx context.Context, e *Enforcer) (string, error)
+}
+
+// Hardening steps outside the core chain, reported without stopping the pass
+var optionalSteps = map[Step]bool{
+	StepServiceConfig: true,
+	StepRecovery:      true,
+}
+
+var pipeline = []step{
+	{StepInstalled, func(ctx context.Context, e *Enforcer) (string, error) {
+		installed, err := warp.IsInstalled()
+		if err != nil {
+			return "", fmt.Errorf("could not check if Warp is installed:\n %w", err)
+		}
+		if !installed {
+			return "", errors.New("Warp is not properly installed! Install it using package manager like 'winget' or other.")
+		}
+		return "Warp is installed", nil
+	}},
+	{StepServiceConfig, func(ctx context.Context, e *Enforcer) (string, error) {
+		if err := e.openServ(); err != nil {
+			return "", err
+		}
+		if err := e.Serv.EnsureConfig(); err != nil {
+			return "", fmt.Errorf("could not ensure Warp service config matches its baseline:\n %w", err)
+		}
+		return "Warp service config matches its baseline", nil
+	}},
+	{StepEnabled, func(ctx context.Context, e *Enforcer) (string, error) {
+		// Opened here too, the service config step does not gate this one
+		if err := e.openServ(); err != nil {
+			return "", err
+		}
+		if err := e.Serv.EnsureIsEnabled(ctx); err != nil {
+			return "", fmt.Errorf("could not ensure Warp service is enabled for startup:\n %w", err)
+		}
+		return "Warp service is enabled", nil
+	}},
+	{StepRunning, func(ctx context.Context, e *Enforcer) (string, error) {
+		if err := e.Serv.EnsureIsRunning(ctx); err != nil {
+			return "", fmt.Errorf("could not ensure Warp service is running:\n %w", err)
+		}
+		return "Warp service is running", nil
+	}},
+	{StepRecovery, func(ctx context.Context, e *Enforcer) (string, error) {
+		if err := e.Serv.EnsureRecoveryActions(); err != nil {
+			return "", fmt.Errorf("could not ensure Warp service recovery actions:\n %w", err)
+		}
+		policy, err := e.Serv.RecoveryPolicy()
+		if err != nil {
+			return "", fmt.Errorf("could not get Warp service recovery policy:\n %w", err)
+		}
+		return fmt.Sprintf("Warp service recovery policy: %v", policy), nil
+	}},
+	{StepConnected, func(ctx context.Context, e *Enforcer) (string, error) {
+		if err := warp.EnsureIsConnected(ctx); err != nil {
+			return "", fmt.Errorf("could not ensure Warp is connected to the Cloudflare service:\n %w", err)
+		}
+		return "Warp is connected to the Cloudflare service", nil
+	}},
+	{StepMode, func(ctx context.Context, e *Enforcer) (string, error) {
+		if err := warp.EnsureMode(); err != nil {
+			return "", fmt.Errorf("could not ensure Warp runs in the required mode:\n %w", err)
+		}
+		return "Warp runs in the required mode", nil
+	}},
+	{StepFamilies, func(ctx context.Context, e *Enforcer) (string, error) {
+		if err := warp.EnsureFamiliesMode(); err != nil {
+			return "", fmt.Errorf("could not ensure Warp families mode:\n %w", err)
+		}
+		return "Warp families mode is set", nil
+	}},
+}
+
+// Names of the steps in pipeline order
+func Steps() []Step {
+	steps := make([]Step, len(pipeline))
+	for i, step := range pipeline {
+		steps[i] = step.name
+	}
+	return steps
+}
+
+// Open the Warp service on first use, or again after it failed
+func (e *Enforcer) openServ() error {
+	if e.Serv != nil {
+		return nil
+	}
+	s, err := warp.Serv.Init()
+	if err != nil {
+		return fmt.Errorf("could not initialize service manager with Warp service:\n %w", err)
+	}
+	e.Serv = s
+	return nil
+}
+
+// Run one enforcement pass, stopping at the first failing core step. Failing
+// optional steps are reported while the pass goes on. The result is logged and
+// written to the status file.
+func (e *Enforcer) Run(ctx context.Context) Result {
+	result := Result{Time: time.Now(), OK: true}
+
+	for _, step := range pipeline {
+		if ctx.Err() != nil {
+			result.OK = false
+			result.Steps = append(result.Steps, StepResult{Step: step.name, Error: ctx.Err().Error()})
+			break
+		}
+
+		started := time.Now()
+		message, err := step.run(ctx, e)
+		stepResult := StepResult{Step: step.name, OK: err == nil, Message: message, Duration: time.Since(started)}
+		if err != nil {
+			stepResult.Error = err.Error()
+		}
+		result.Steps = append(result.Steps, stepResult)
+
+		if err != nil {
+			result.OK = false
+			if optionalSteps[step.name] {
+				continue
+			}
+			// Reopen the service on the next pass in case its handle went stale
+			if step.name != StepInstalled {
+				e.Close()
+			}
+			break
+		}
+	}
+	result.Duration = time.Since(result.Time)
+
+	logResult(result)
+	if err := WriteStatus(result); err != nil {
+		log.Warn().Msgf("Could not write enforcement status:\n %v", err)
+	}
+	if err := Metrics.WriteFile(MetricsPath()); err != nil {
+		log.Warn().Msgf("Could not write enforcement metrics:\n %v", err)
+	}
+	return result
+}
+
+func logResult(result Result) {
+	if failed := result.Failed(); failed != nil {
+		log.Error().
+			Str("result", "failed").
+			Str("step", string(failed.Step)).
+			Dur("duration", result.Duration).
+			Msgf("Enforcement pass failed:\n %v", failed.Error)
+		return
+	}
+	log.Info().
+		Str("result", "ok").
+		Int("steps", len(result.Steps)).
+		Dur("duration", result.Duration).
+		Msg("Enforcement pass completed")
+}
+
+func StatusPath() string {
+	return filepath.Join(config.App().InstallPath, StatusFileName)
+}
+
+func MetricsPath() string {
+	return filepath.Join(config.App().InstallPath, MetricsFileName)
+}
+
+// Write the result of a pass to the status file, replacing it atomically
+func WriteStatus(result Result) error {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := StatusPath()
+	tmpPath := path + ".tmp"
+	if err = os.WriteFile(tmpPath, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// Result of the last pass written to the status file
+func ReadStatus() (Result, error) {
+	var result Result
+	data, err := os.ReadFile(StatusPath())
+	if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
@@ -0,0 +1,74 @@
+package enforce
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/ezydark/ezforce/app/config"
+)
+
+// Replace the pipeline with steps failing as given, recording which ran
+func usePipeline(t *testing.T, failing map[Step]bool) *[]Step {
+	t.Helper()
+	t.Setenv("EZFORCE_APP_INSTALLPATH", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if err := config.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	var ran []Step
+	steps := make([]step, len(pipeline))
+	for i, s := range pipeline {
+		name := s.name
+		steps[i] = step{name, func(ctx context.Context, e *Enforcer) (string, error) {
+			ran = append(ran, name)
+			if failing[name] {
+				return "", errors.New("failed")
+			}
+			return "ok", nil
+		}}
+	}
+
+	original := pipeline
+	pipeline = steps
+	t.Cleanup(func() { pipeline = original })
+	return &ran
+}
+
+func TestRunDoesNotGateOnOptionalSteps(t *testing.T) {
+	ran := usePipeline(t, map[Step]bool{StepServiceConfig: true, StepRecovery: true})
+
+	result := New().Run(context.Background())
+	if !slices.Equal(*ran, Steps()) {
+		t.Errorf("ran %v, want every step %v", *ran, Steps())
+	}
+	if result.OK {
+		t.Error("pass OK although optional steps failed")
+	}
+	if failed := result.Failed(); failed == nil || failed.Step != StepRecovery {
+		t.Errorf("Failed() = %v, want the recovery step", failed)
+	}
+}
+
+func TestRunStopsAtCoreStep(t *testing.T) {
+	ran := usePipeline(t, map[Step]bool{StepServiceConfig: true, StepRunning: true})
+
+	result := New().Run(context.Background())
+	want := []Step{StepInstalled, StepServiceConfig, StepEnabled, StepRunning}
+	if !slices.Equal(*ran, want) {
+		t.Errorf("ran %v, want %v", *ran, want)
+	}
+	if failed := result.Failed(); failed == nil || failed.Step != StepRunning {
+		t.Errorf("Failed() = %v, want the running step", failed)
+	}
+
+	status, err := ReadStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.OK || len(status.Steps) != len(want) {
+		t.Errorf("status file = %+v, want the failed pass", status)
+	}
+}
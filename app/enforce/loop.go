@@ -0,0 +1,101 @@
+package enforce
+
+import (
+	"context"
+	"time"
+
+	"github.com/ezydark/ezforce/app/config"
+	"github.com/ezydark/ezforce/libs/logger"
+	"github.com/ezydark/ezforce/libs/warp/serv"
+	"github.com/rs/zerolog/log"
+)
+
+// How often the config files are checked for changes
+const configWatchInterval = 2 * time.Second
+
+// Run an enforcement pass right away, then on every check interval, whenever
+// the Warp service leaves the running state and whenever the config changes,
+// until ctx is done
+func Loop(ctx context.Context) {
+	e := New()
+	defer e.Close()
+
+	configChanges := config.Watch(ctx, configWatchInterval)
+
+	checkInterval := config.App().CheckInterval
+	ticker := time.NewTicker(time.Duration(checkInterval) * time.Second)
+	defer ticker.Stop()
+
+	// Watch of the currently open Warp service, restarted whenever it is reopened
+	var serviceChanges <-chan serv.StateChange
+	var watched *serv.WarpServ
+	stopWatch := func() {}
+	defer func() { stopWatch() }()
+
+	run := func() {
+		e.Run(ctx)
+		if e.Serv == watched {
+			return
+		}
+
+		stopWatch()
+		serviceChanges, watched, stopWatch = nil, nil, func() {}
+		if e.Serv == nil {
+			return
+		}
+
+		watchCtx, cancel := context.WithCancel(ctx)
+		changes, err := e.Serv.Watch(watchCtx)
+		if err != nil {
+			cancel()
+			log.Warn().Msgf("Could not watch Warp service, relying on periodic checks:\n %v", err)
+			return
+		}
+		serviceChanges, watched, stopWatch = changes, e.Serv, cancel
+	}
+
+	run()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			run()
+
+		case change, ok := <-serviceChanges:
+			if !ok {
+				serviceChanges = nil
+				continue
+			}
+			log.Warn().
+				Str("from", string(change.From)).
+				Str("to", string(change.To)).
+				Msg("Warp service changed its state")
+			if change.To != serv.StateRunning && change.To != serv.StateStartPending {
+				run()
+			}
+
+		case _, ok := <-configChanges:
+			if !ok {
+				configChanges = nil
+				continue
+			}
+			err := config.Reload()
+			if err != nil {
+				log.Error().Msgf("Rejected config change, keeping the last good config:\n %v", err)
+				continue
+			}
+			log.Info().Msg("Config reloaded")
+			app := config.App()
+			if err := logger.Configure(app.LogLevel, app.LogFormat); err != nil {
+				log.Warn().Msgf("Could not reconfigure logger:\n %v", err)
+			}
+			if app.CheckInterval != checkInterval {
+				checkInterval = app.CheckInterval
+				ticker.Reset(time.Duration(checkInterval) * time.Second)
+			}
+			run()
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package guardian
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/ezydark/ezforce/app/config"
+	"github.com/ezydark/ezforce/libs/logger"
+	"github.com/ezydark/ezforce/libs/wait"
+	processutil "github.com/ezydark/ezforce/libs/win/process"
+	"github.com/rs/zerolog/log"
+)
+
+// Command the guardian process is started with, followed by "--peer <pid>"
+const Command = "guardian"
+
+// Name of the file the guardian leaves next to the install path when it restarts the
+// service. The guardian only logs to its console, so the restarted service logs the alert.
+const RestartMarkerName = "guardian.restart"
+
+// How often the guardian and the service check on each other
+var CheckInterval = 2 * time.Second
+
+// How long the guardian waits for a stopping or starting service to settle
+var SettlePolicy = wait.Policy{
+	InitialDelay: 500 * time.Millisecond,
+	MaxDelay:     2 * time.Second,
+	Multiplier:   1.5,
+	Timeout:      60 * time.Second,
+}
+
+// Operations on the ezForce service needed to restart it
+type Service struct {
+	// Current state of the service, e.g. "Running"
+	Status func() (string, error)
+	Start  func() error
+}
+
+var process = &processutil.Process{}
+
+// Restart of the service by the guardian, reported by the restarted service
+type restartMarker struct {
+	Time time.Time `json:"time"`
+	// PID of the service process that exited
+	PID int32 `json:"pid"`
+}
+
+// Path of the restart marker
+func RestartMarkerPath() string {
+	return filepath.Join(config.App().InstallPath, RestartMarkerName)
+}
+
+// Log the alert of a restart by the guardian and remove its marker, to be called
+// by the service once its log outputs are set up
+func ReportRestart() {
+	path := RestartMarkerPath()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		log.Warn().Msgf("Could not read guardian restart marker '%s':\n %v", path, err)
+		return
+	}
+
+	var marker restartMarker
+	if err := json.Unmarshal(data, &marker); err != nil {
+		log.Warn().Msgf("Invalid guardian restart marker '%s':\n %v", path, err)
+	}
+	log.Error().Bool("alert", true).Int32("pid", marker.PID).Time("exited", marker.Time).
+		Msg("ezForce service restarted by the guardian")
+
+	if err := os.Remove(path); err != nil {
+		log.Warn().Msgf("Could not remove guardian restart marker '%s':\n %v", path, err)
+	}
+}
+
+// Start a guardian process watching this one
+func Spawn() (*processutil.TrackedProcess, error) {
+	exePath, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("could not get executable path:\n %w", err)
+	}
+	pid, err := process.StartProcess(exePath, Command, "--peer", strconv.Itoa(os.Getpid()))
+	if err != nil {
+		return nil, fmt.Errorf("could not start guardian process:\n %w", err)
+	}
+	return process.Track(pid)
+}
+
+// Keep a guardian process running while the config enables it, until ctx is done.
+// The guardian is left running afterwards, so it can restart the stopped service.
+func Protect(ctx context.Context) {
+	var guardian *processutil.TrackedProcess
+
+	check := func() {
+		// The service loop reloads the config concurrently, read its snapshot
+		if !config.App().Guardian {
+			guardian = nil
+			return
+		}
+
+		if guardian != nil {
+			alive, err := guardian.IsAlive()
+			if err != nil {
+				log.Warn().Msgf("Could not check guardian process:\n %v", err)
+				return
+			}
+			if alive {
+				return
+			}
+			log.Error().Bool("alert", true).Int32("pid", guardian.PID).
+				Msg("Guardian process exited, restarting it")
+		}
+
+		var err error
+		guardian, err = Spawn()
+		if err != nil {
+			log.Error().Msgf("Could not start guardian process:\n %v", err)
+			return
+		}
+		log.Info().Int32("pid", guardian.PID).Msg("Guardian process started")
+	}
+
+	ticker := time.NewTicker(CheckInterval)
+	defer ticker.Stop()
+
+	check()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			check()
+		}
+	}
+}
+
+// Watch the service process with the given PID and restart the service once it
+// exits. Returns after the restart, the restarted service starts a new guardian.
+func Guard(ctx context.Context, peerPID int32, service Service) error {
+	peer, err := process.Track(peerPID)
+	if err != nil {
+		return fmt.Errorf("could not track ezForce service process:\n %w", err)
+	}
+	log.Info().Int32("pid", peerPID).Msg("Guarding ezForce service process")
+
+	configChanges := config.Watch(ctx, CheckInterval)
+	ticker := time.NewTicker(CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case _, ok := <-configChanges:
+			if !ok {
+				configChanges = nil
+				continue
+			}
+			if err := config.Reload(); err != nil {
+				log.Error().Msgf("Rejected config change, keeping the last good config:\n %v", err)
+				continue
+			}
+			app := config.App()
+			if !app.Guardian {
+				log.Info().Msg("Guardian disabled by the config, exiting")
+				return nil
+			}
+			if err := logger.Configure(app.LogLevel, app.LogFormat); err != nil {
+				log.Warn().Msgf("Could not reconfigure logger:\n %v", err)
+			}
+
+		case <-ticker.C:
+			alive, err := peer.IsAlive()
+			if err != nil {
+				log.Warn().Msgf("Could not check ezForce service process:\n %v", err)
+				continue
+			}
+			if alive {
+				continue
+			}
+			log.Error().Bool("alert", true).Int32("pid", peerPID).
+				Msg("ezForce service process exited, restarting the service")
+			return restart(ctx, service, restartMarker{Time: time.Now(), PID: peerPID})
+		}
+	}
+}
+
+// Start the service unless its service manager already restarted it
+func restart(ctx context.Context, service Service, marker restartMarker) error {
+	var state string
+	err := wait.WaitFor(ctx, func(ctx context.Context) (bool, error) {
+		var err error
+		state, err = service.Status()
+		if err != nil {
+			return false, err
+		}
+		return state != "StopPending" && state != "StartPending", nil
+	}, SettlePolicy)
+	if err != nil {
+		return fmt.Errorf("could not get ezForce service state:\n %w", err)
+	}
+
+	if state == "Running" {
+		log.Info().Msg("ezForce service was already restarted by its service manager")
+		return nil
+	}
+
+	// Written before starting, so that the service finds it once it runs
+	path := RestartMarkerPath()
+	data, err := json.Marshal(marker)
+	if err == nil {
+		err = os.WriteFile(path, data, 0644)
+	}
+	if err != nil {
+		log.Warn().Msgf("Could not write guardian restart marker '%s':\n %v", path, err)
+	}
+
+	err = service.Start()
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("could not restart ezForce service:\n %w", err)
+	}
+	log.Error().Bool("alert", true).Msg("ezForce service restarted by the guardian")
+	return nil
+}
@@ -0,0 +1,100 @@
+package guardian
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ezydark/ezforce/app/config"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Protect reads the config while the service loop reloads it, run with -race
+func TestProtectDuringReload(t *testing.T) {
+	t.Setenv("EZFORCE_APP_INSTALLPATH", t.TempDir())
+	t.Setenv("EZFORCE_APP_GUARDIAN", "false")
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	interval := CheckInterval
+	CheckInterval = time.Millisecond
+	t.Cleanup(func() { CheckInterval = interval })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		Protect(ctx)
+		close(done)
+	}()
+
+	for i := 0; i < 50; i++ {
+		if err := config.Reload(); err != nil {
+			t.Fatalf("Reload() error = %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+}
+
+// The restarted service logs the alert the guardian could only print to its console
+func TestRestartReportedByService(t *testing.T) {
+	t.Setenv("EZFORCE_APP_INSTALLPATH", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if err := config.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	var output bytes.Buffer
+	logger := log.Logger
+	log.Logger = zerolog.New(&output)
+	t.Cleanup(func() { log.Logger = logger })
+
+	started := false
+	service := Service{
+		Status: func() (string, error) { return "Stopped", nil },
+		Start:  func() error { started = true; return nil },
+	}
+	if err := restart(context.Background(), service, restartMarker{Time: time.Now(), PID: 42}); err != nil || !started {
+		t.Fatalf("restart() = %v, started %v, want the service started", err, started)
+	}
+
+	output.Reset()
+	ReportRestart()
+	if !strings.Contains(output.String(), `"alert":true`) || !strings.Contains(output.String(), `"pid":42`) ||
+		!strings.Contains(output.String(), "restarted by the guardian") {
+		t.Errorf("ReportRestart() logged %s, want the restart alert", output.String())
+	}
+	if _, err := os.Stat(RestartMarkerPath()); !os.IsNotExist(err) {
+		t.Errorf("restart marker kept after reporting it, stat error = %v", err)
+	}
+
+	output.Reset()
+	ReportRestart()
+	if output.Len() > 0 {
+		t.Errorf("ReportRestart() without a restart logged %s", output.String())
+	}
+}
+
+func TestFailedRestartLeavesNoMarker(t *testing.T) {
+	t.Setenv("EZFORCE_APP_INSTALLPATH", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if err := config.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	service := Service{
+		Status: func() (string, error) { return "Stopped", nil },
+		Start:  func() error { return errors.New("access denied") },
+	}
+	if err := restart(context.Background(), service, restartMarker{Time: time.Now(), PID: 42}); err == nil {
+		t.Fatal("restart() succeeded with a failing start")
+	}
+	if _, err := os.Stat(RestartMarkerPath()); !os.IsNotExist(err) {
+		t.Errorf("restart marker left after a failed restart, stat error = %v", err)
+	}
+}
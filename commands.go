@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ezydark/ezforce/app/config"
+	"github.com/ezydark/ezforce/app/enforce"
+	"github.com/ezydark/ezforce/app/guardian"
+	"github.com/ezydark/ezforce/libs/audit"
+	"github.com/ezydark/ezforce/libs/logger"
+	"github.com/ezydark/ezforce/libs/warp"
+	"github.com/ezydark/ezforce/libs/win"
+	"github.com/rs/zerolog/log"
+)
+
+// Exit codes of the commands
+const (
+	exitOK         = 0
+	exitError      = 1
+	exitUsage      = 2
+	exitNotRunning = 3
+	exitNotAdmin   = 4
+	exitTampered   = 5
+	// Failed enforcement steps exit with exitStepBase plus the step's position in the pipeline
+	exitStepBase = 10
+)
+
+// Exit code signaling that an enforcement step failed
+func stepExitCode(step enforce.Step) int {
+	for i, s := range enforce.Steps() {
+		if s == step {
+			return exitStepBase + i
+		}
+	}
+	return exitError
+}
+
+// Platform's implementation of the ezForce service commands
+type serviceBackend struct {
+	install func() error
+	remove  func() error
+	start   func() error
+	stop    func() error
+	// Current state of the service, e.g. "Running"
+	status func() (string, error)
+	// Check if the process was started by the service manager
+	isService func() (bool, error)
+	// Run under the service manager
+	run func() error
+	// Run the service logic on the console
+	debug func() error
+}
+
+// Run the command given on the command line and return the process exit code
+func runCommand(args []string) int {
+	switch args[0] {
+	case "config":
+		return configCommand(args[1:])
+	case "audit":
+		return auditCommand(args[1:])
+	}
+
+	if err := config.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid config:\n%v\n", err)
+		return exitError
+	}
+
+	switch args[0] {
+	case "install":
+		return adminCommand("install", service.install)
+	case "remove":
+		return adminCommand("remove", service.remove)
+	case "start":
+		return adminCommand("start", service.start)
+	case "stop":
+		return adminCommand("stop", service.stop)
+	case "status":
+		return statusCommand()
+	case "run":
+		return runServiceCommand(args[1:])
+	case "debug":
+		return adminCommand("debug", service.debug)
+	case guardian.Command:
+		return guardianCommand(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command '%s'\n", args[0])
+		usage()
+		return exitUsage
+	}
+}
+
+// Run a command that needs admin rights, failing instead of asking for elevation
+func adminCommand(name string, command func() error) int {
+	if !win.Admin.IsSelfAdmin() {
+		fmt.Fprintf(os.Stderr, "Command '%s' must be run as administrator\n", name)
+		return exitNotAdmin
+	}
+	if err := command(); err != nil {
+		fmt.Fprintf(os.Stderr, "Command '%s' failed: %v\n", name, err)
+		return exitError
+	}
+	fmt.Printf("Command '%s' completed successfully\n", name)
+	return exitOK
+}
+
+// Run the enforcement loop, under the service manager when started by it
+func runServiceCommand(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	foreground := fs.Bool("foreground", false, "Run in the foreground even when started by the service manager")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if !*foreground {
+		isService, err := service.isService()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Could not determine if running as a service:", err)
+			return exitError
+		}
+		if isService {
+			logToFile()
+			guardian.ReportRestart()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go guardian.Protect(ctx)
+
+			if err := service.run(); err != nil {
+				log.Error().Msgf("Service failed:\n %v", err)
+				return exitError
+			}
+			return exitOK
+		}
+	}
+
+	if !win.Admin.IsSelfAdmin() {
+		fmt.Fprintln(os.Stderr, "Command 'run' must be run as administrator")
+		return exitNotAdmin
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	log.Info().Msg("Enforcing in the foreground, press Ctrl+C to stop")
+	enforce.Loop(ctx)
+	log.Info().Msg("Stopped enforcing")
+	return exitOK
+}
+
+// Also log to the rotated log file next to the install path. Only the service
+// owns the file, so that a single process rotates it.
+func logToFile() {
+	app := config.App()
+	logPath := filepath.Join(app.InstallPath, app.LogFileName)
+	err := logger.AddFile(logPath, int64(app.LogMaxSize)<<20,
+		time.Duration(app.LogMaxAge)*24*time.Hour, app.LogMaxBackups)
+	if err != nil {
+		log.Warn().Msgf("Could not open log file '%s', logging to console only:\n %v", logPath, err)
+	}
+}
+
+// Watch the ezForce service process and restart the service when it exits
+func guardianCommand(args []string) int {
+	fs := flag.NewFlagSet(guardian.Command, flag.ContinueOnError)
+	peer := fs.Int("peer", 0, "PID of the ezForce service process to guard")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *peer <= 0 {
+		fmt.Fprintln(os.Stderr, "Missing service process, pass its PID with --peer")
+		return exitUsage
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err := guardian.Guard(ctx, int32(*peer), guardian.Service{Status: service.status, Start: service.start})
+	if err != nil {
+		log.Error().Msgf("Guardian failed:\n %v", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// Print the state of the ezForce and Warp services and the last enforcement pass.
+// Exits with exitNotRunning when the ezForce service is not running.
+func statusCommand() int {
+	code := exitOK
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer tw.Flush()
+
+	state, err := service.status()
+	if err != nil {
+		fmt.Fprintf(tw, "ezForce service:\t%v\n", err)
+		code = exitNotRunning
+	} else {
+		fmt.Fprintf(tw, "ezForce service:\t%s\n", state)
+		if state != "Running" {
+			code = exitNotRunning
+		}
+	}
+
+	warpServ, err := warp.Serv.Init()
+	if err != nil {
+		fmt.Fprintf(tw, "Warp service:\t%v\n", err)
+	} else {
+		defer warpServ.Close()
+		running, runErr := warpServ.IsRunning()
+		enabled, enabledErr := warpServ.IsEnabled()
+		if err = errors.Join(runErr, enabledErr); err != nil {
+			fmt.Fprintf(tw, "Warp service:\t%v\n", err)
+		} else {
+			fmt.Fprintf(tw, "Warp service:\trunning=%v enabled=%v\n", running, enabled)
+		}
+
+		policy, err := warpServ.RecoveryPolicy()
+		if err != nil {
+			fmt.Fprintf(tw, "Warp recovery policy:\t%v\n", err)
+		} else {
+			fmt.Fprintf(tw, "Warp recovery policy:\t%v\n", policy)
+		}
+	}
+
+	result, err := enforce.ReadStatus()
+	if err != nil {
+		fmt.Fprintf(tw, "Last enforcement pass:\tunknown (%v)\n", err)
+	} else if failed := result.Failed(); failed != nil {
+		fmt.Fprintf(tw, "Last enforcement pass:\t%s, failed at step '%s'\n", result.Time.Format("2006-01-02 15:04:05"), failed.Step)
+	} else {
+		fmt.Fprintf(tw, "Last enforcement pass:\t%s, ok (%d steps in %v)\n",
+			result.Time.Format("2006-01-02 15:04:05"), len(result.Steps), result.Duration)
+	}
+
+	return code
+}
+
+func auditCommand(args []string) int {
+	if len(args) == 0 || args[0] != "verify" {
+		usage()
+		return exitUsage
+	}
+
+	fs := flag.NewFlagSet("audit verify", flag.ContinueOnError)
+	if err := fs.Parse(args[1:]); err != nil {
+		return exitUsage
+	}
+	path, headPath, keyPath := audit.Path(), audit.HeadPath(), audit.KeyPath()
+	if fs.NArg() > 0 {
+		// The head and key are expected next to the given log
+		path = fs.Arg(0)
+		headPath = filepath.Join(filepath.Dir(path), audit.HeadFileName)
+		keyPath = filepath.Join(filepath.Dir(path), audit.KeyFileName)
+	}
+
+	report, err := audit.Verify(path, headPath, keyPath)
+	if errors.Is(err, audit.ErrTampered) {
+		fmt.Fprintf(os.Stderr, "Audit log '%s' failed verification after %d intact entries:\n %v\n", path, report.Entries, err)
+		return exitTampered
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Could not verify audit log:", err)
+		return exitError
+	}
+
+	// Install records its first entry, the log was deleted when the service has none
+	if report.Entries == 0 && fs.NArg() == 0 {
+		if _, err := service.status(); err == nil {
+			fmt.Fprintf(os.Stderr, "Audit log '%s' failed verification:\n %v: the service is installed but the log has no entries\n", path, audit.ErrTampered)
+			return exitTampered
+		}
+	}
+
+	fmt.Printf("Audit log '%s' is intact, %d entries verified\n", path, report.Entries)
+	if report.HeadLagging {
+		fmt.Println("The last entry was written without moving the head, e.g. after a crash")
+	}
+	return exitOK
+}
+
+func configCommand(args []string) int {
+	if len(args) == 0 {
+		usage()
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "show":
+		fs := flag.NewFlagSet("config show", flag.ContinueOnError)
+		withOrigin := fs.Bool("origin", false, "Print which layer set each value")
+		if err := fs.Parse(args[1:]); err != nil {
+			return exitUsage
+		}
+		if err := config.Show(os.Stdout, *withOrigin); err != nil {
+			fmt.Fprintln(os.Stderr, "Could not show config:", err)
+			return exitError
+		}
+		return exitOK
+	case "sign":
+		fs := flag.NewFlagSet("config sign", flag.ContinueOnError)
+		keyPath := fs.String("key", "", "PEM encoded Ed25519 private key of the admin")
+		if err := fs.Parse(args[1:]); err != nil {
+			return exitUsage
+		}
+		if *keyPath == "" {
+			fmt.Fprintln(os.Stderr, "Missing private key, pass it with --key")
+			return exitUsage
+		}
+		path := config.SystemConfigPath()
+		if fs.NArg() > 0 {
+			path = fs.Arg(0)
+		}
+		if err := config.Sign(path, *keyPath); err != nil {
+			fmt.Fprintln(os.Stderr, "Could not sign config:", err)
+			return exitError
+		}
+		fmt.Printf("Signed '%s'\n", path)
+		return exitOK
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown config command '%s'\n", args[0])
+		usage()
+		return exitUsage
+	}
+}
+
+func usage() {
+	tw := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Usage: %s [flags] [command]\n\n", os.Args[0])
+	fmt.Fprintf(tw, "Commands:\n")
+	fmt.Fprintf(tw, "  (none)\tEnforce Warp once, prompting unless non-interactive\n")
+	fmt.Fprintf(tw, "  install\tInstall the ezForce service\n")
+	fmt.Fprintf(tw, "  remove\tRemove the ezForce service\n")
+	fmt.Fprintf(tw, "  start\tStart the ezForce service\n")
+	fmt.Fprintf(tw, "  stop\tStop the ezForce service\n")
+	fmt.Fprintf(tw, "  status\tPrint the state of the services and the last enforcement pass\n")
+	fmt.Fprintf(tw, "  run [--foreground]\tEnforce continuously, as the service or in the foreground\n")
+	fmt.Fprintf(tw, "  debug\tRun the service logic on the console\n")
+	fmt.Fprintf(tw, "  guardian --peer <pid>\tRestart the service when its process exits, started by the service\n")
+	fmt.Fprintf(tw, "  audit verify [file]\tCheck that the audit log was not edited or truncated, as administrator\n")
+	fmt.Fprintf(tw, "  config show [--origin]\tPrint the effective config\n")
+	fmt.Fprintf(tw, "  config sign --key <private key> [file]\tSign a config file\n")
+	fmt.Fprintf(tw, "\nExit codes:\n")
+	fmt.Fprintf(tw, "  %d\tSuccess\n", exitOK)
+	fmt.Fprintf(tw, "  %d\tFailure\n", exitError)
+	fmt.Fprintf(tw, "  %d\tUsage error\n", exitUsage)
+	fmt.Fprintf(tw, "  %d\tService not running\n", exitNotRunning)
+	fmt.Fprintf(tw, "  %d\tNot running as administrator\n", exitNotAdmin)
+	fmt.Fprintf(tw, "  %d\tAudit log failed verification\n", exitTampered)
+	for _, step := range enforce.Steps() {
+		fmt.Fprintf(tw, "  %d\tEnforcement step '%s' failed\n", stepExitCode(step), step)
+	}
+	tw.Flush()
+	fmt.Fprintf(os.Stderr, "\nFlags:\n")
+	flag.PrintDefaults()
+}
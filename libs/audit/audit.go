@@ -0,0 +1,350 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ezydark/ezforce/app/config"
+	"github.com/ezydark/ezforce/libs/events"
+	"github.com/ezydark/ezforce/libs/logger"
+)
+
+// Logger of the package, tagged with its component
+var log = logger.Component("audit")
+
+// Name of the append-only audit log, next to the install path
+const FileName = "audit.log"
+
+// Name of the file holding the sequence number and MAC of the last entry.
+// It reveals entries cut off the end of the log, which the chain alone cannot.
+const HeadFileName = "audit.head"
+
+// Previous hash of the first entry
+var genesisHash = strings.Repeat("0", sha256.Size*2)
+
+// Returned by Verify when the log was edited or truncated
+var ErrTampered = errors.New("audit log was tampered with")
+
+// Enforcement action recorded in the audit log, one JSON line per entry.
+// Each entry carries the keyed MAC of the previous line, chaining them together.
+type Entry struct {
+	Seq  uint64    `json:"seq"`
+	Time time.Time `json:"time"`
+	// Package that took the action, e.g. "warp/serv"
+	Component string `json:"component"`
+	// Enforcement step, e.g. "enabled"
+	Step string `json:"step"`
+	// What was found and what was required, e.g. "disabled" and "enabled"
+	Observed string `json:"observed"`
+	Desired  string `json:"desired"`
+	// What was done about it, e.g. "enable"
+	Action string `json:"action"`
+	// Why the action failed and where, empty when it succeeded
+	Error      string `json:"error,omitempty"`
+	ErrorClass string `json:"errorClass,omitempty"`
+	// Hex HMAC-SHA256 of the previous line
+	Prev string `json:"prev"`
+}
+
+// Sequence number and head MAC of the last entry written
+type head struct {
+	Seq  uint64 `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// Serializes the appends of this process
+var mu sync.Mutex
+
+// Path of the audit log
+func Path() string {
+	return filepath.Join(config.App().InstallPath, FileName)
+}
+
+// Path of the head of the audit log
+func HeadPath() string {
+	return filepath.Join(config.App().InstallPath, HeadFileName)
+}
+
+// Event sink recording every corrective action in the audit log, events
+// without an action are left to the debug log.
+// A log that cannot be written is reported but does not stop the enforcement.
+type Sink struct{}
+
+func (Sink) Emit(e events.Event) {
+	if e.Action == events.ActionNone {
+		return
+	}
+
+	entry := Entry{
+		Time:       e.Time.UTC(),
+		Component:  e.Component,
+		Step:       string(e.Step),
+		Observed:   e.Observed,
+		Desired:    e.Desired,
+		Action:     string(e.Action),
+		ErrorClass: string(e.ErrClass),
+	}
+	if e.Err != nil {
+		entry.Error = e.Err.Error()
+	}
+	if err := Append(Path(), HeadPath(), KeyPath(), entry); err != nil {
+		log.Error().Msgf("Could not record '%s' of step '%s' in the audit log:\n %v", e.Action, e.Step, err)
+	}
+}
+
+// Action of the entry recording the install
+const installAction = "install"
+
+// Record the install as the first entry, so that a log missing afterwards was deleted
+func RecordInstall() error {
+	return Append(Path(), HeadPath(), KeyPath(), Entry{
+		Component: "ezforce",
+		Step:      installAction,
+		Observed:  "not installed",
+		Desired:   "installed",
+		Action:    installAction,
+	})
+}
+
+// Append an entry to the audit log at path, filling in its sequence number,
+// time and previous MAC, then move the head to it. The key is created with the log.
+func Append(path string, headPath string, keyPath string, entry Entry) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	last, err := lastLine(path)
+	if err != nil {
+		return err
+	}
+
+	key, err := readKey(keyPath)
+	if errors.Is(err, os.ErrNotExist) {
+		// A new key for an existing log would cover up whatever happened to the old one
+		if last != nil {
+			return fmt.Errorf("%w: key is missing", ErrTampered)
+		}
+		key, err = createKey(keyPath)
+	}
+	if err != nil {
+		return fmt.Errorf("could not read audit key:\n %w", err)
+	}
+
+	entry.Seq = 1
+	entry.Prev = genesisHash
+	if last != nil {
+		var previous Entry
+		if err := json.Unmarshal(last, &previous); err != nil {
+			return fmt.Errorf("invalid last entry of audit log:\n %w", err)
+		}
+		entry.Seq = previous.Seq + 1
+		entry.Prev = chainMAC(key, last)
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("could not encode audit entry:\n %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("could not create audit log directory:\n %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("could not open audit log:\n %w", err)
+	}
+	_, err = f.Write(append(line, '\n'))
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("could not write audit log:\n %w", err)
+	}
+
+	return writeHead(headPath, head{Seq: entry.Seq, Hash: headMAC(key, line)})
+}
+
+// Result of verifying the audit log
+type Report struct {
+	// Number of entries whose chain is intact
+	Entries uint64
+	// The last entry was written but the head was not moved to it, e.g. after a crash
+	HeadLagging bool
+}
+
+// Check the MAC chain of the audit log at path and that it ends at its head.
+// Returns ErrTampered wrapped with the first problem found. A log deleted along
+// with its head and key is only noticed by the caller, as the install entry is missing.
+func Verify(path string, headPath string, keyPath string) (Report, error) {
+	var report Report
+
+	key, err := readKey(keyPath)
+	keyMissing := errors.Is(err, os.ErrNotExist)
+	if err != nil && !keyMissing {
+		return report, fmt.Errorf("could not read audit key:\n %w", err)
+	}
+
+	h, err := readHead(headPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return report, err
+	}
+	headMissing := errors.Is(err, os.ErrNotExist)
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		switch {
+		case !headMissing:
+			return report, fmt.Errorf("%w: log is missing but its head points to entry %d", ErrTampered, h.Seq)
+		case !keyMissing:
+			return report, fmt.Errorf("%w: log is missing but its key was created with it", ErrTampered)
+		}
+		return report, nil
+	}
+	if err != nil {
+		return report, fmt.Errorf("could not open audit log:\n %w", err)
+	}
+	defer f.Close()
+	if keyMissing {
+		return report, fmt.Errorf("%w: key is missing", ErrTampered)
+	}
+
+	// MACs of the last two lines, for the head that may lag one entry behind
+	prevMAC := genesisHash
+	var lastHead, prevHead string
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				return report, fmt.Errorf("%w: entry %d is cut off", ErrTampered, report.Entries+1)
+			}
+			break
+		}
+		if err != nil {
+			return report, fmt.Errorf("could not read audit log:\n %w", err)
+		}
+		line = bytes.TrimSuffix(line, []byte{'\n'})
+
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return report, fmt.Errorf("%w: line %d is not a valid entry", ErrTampered, report.Entries+1)
+		}
+		if entry.Seq != report.Entries+1 {
+			return report, fmt.Errorf("%w: entry %d has sequence number %d", ErrTampered, report.Entries+1, entry.Seq)
+		}
+		if !hmac.Equal([]byte(entry.Prev), []byte(prevMAC)) {
+			return report, fmt.Errorf("%w: entry %d does not chain to the previous one", ErrTampered, entry.Seq)
+		}
+
+		report.Entries++
+		prevMAC = chainMAC(key, line)
+		prevHead, lastHead = lastHead, headMAC(key, line)
+	}
+
+	if headMissing {
+		if report.Entries == 0 {
+			return report, nil
+		}
+		return report, fmt.Errorf("%w: head is missing, entries may have been cut off", ErrTampered)
+	}
+	switch {
+	case h.Seq == report.Entries && h.Seq > 0 && hmac.Equal([]byte(h.Hash), []byte(lastHead)):
+	case h.Seq+1 == report.Entries && h.Seq > 0 && hmac.Equal([]byte(h.Hash), []byte(prevHead)):
+		report.HeadLagging = true
+	case h.Seq > report.Entries:
+		return report, fmt.Errorf("%w: log ends at entry %d but its head points to entry %d", ErrTampered, report.Entries, h.Seq)
+	default:
+		return report, fmt.Errorf("%w: head does not match entry %d", ErrTampered, h.Seq)
+	}
+	return report, nil
+}
+
+// Last line of the file without its newline, nil when the file is empty or missing
+func lastLine(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not open audit log:\n %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("could not stat audit log:\n %w", err)
+	}
+
+	// Read backwards in chunks until the newline before the last line
+	const chunkSize = 4096
+	var tail []byte
+	for offset := info.Size(); offset > 0; {
+		size := int64(chunkSize)
+		if offset < size {
+			size = offset
+		}
+		offset -= size
+
+		chunk := make([]byte, size)
+		if _, err := f.ReadAt(chunk, offset); err != nil {
+			return nil, fmt.Errorf("could not read audit log:\n %w", err)
+		}
+		tail = append(chunk, tail...)
+
+		trimmed := bytes.TrimSuffix(tail, []byte{'\n'})
+		if i := bytes.LastIndexByte(trimmed, '\n'); i >= 0 {
+			return trimmed[i+1:], nil
+		}
+	}
+
+	tail = bytes.TrimSuffix(tail, []byte{'\n'})
+	if len(tail) == 0 {
+		return nil, nil
+	}
+	return tail, nil
+}
+
+func readHead(path string) (head, error) {
+	var h head
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return h, err
+	}
+	if err := json.Unmarshal(data, &h); err != nil {
+		return h, fmt.Errorf("%w: head is not valid", ErrTampered)
+	}
+	return h, nil
+}
+
+// Replace the head atomically, so it is never seen half written
+func writeHead(path string, h head) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return fmt.Errorf("could not encode audit head:\n %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("could not write audit head:\n %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("could not write audit head:\n %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/ezydark/ezforce/app/config"
+)
+
+type paths struct {
+	log, head, key string
+}
+
+func newLog(t *testing.T, entries int) paths {
+	t.Helper()
+	dir := t.TempDir()
+	p := paths{
+		log:  filepath.Join(dir, FileName),
+		head: filepath.Join(dir, HeadFileName),
+		key:  filepath.Join(dir, KeyFileName),
+	}
+	for i := 0; i < entries; i++ {
+		entry := Entry{Component: "warp", Step: "mode", Observed: "doh", Desired: "warp", Action: "set-mode"}
+		if err := Append(p.log, p.head, p.key, entry); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+	return p
+}
+
+func readLines(t *testing.T, path string) [][]byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.SplitAfter(bytes.TrimSuffix(data, []byte{'\n'}), []byte{'\n'})
+}
+
+func writeLines(t *testing.T, path string, lines [][]byte) {
+	t.Helper()
+	data := bytes.Join(lines, nil)
+	if !bytes.HasSuffix(data, []byte{'\n'}) {
+		data = append(data, '\n')
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyIntact(t *testing.T) {
+	p := newLog(t, 3)
+	report, err := Verify(p.log, p.head, p.key)
+	if err != nil || report.Entries != 3 || report.HeadLagging {
+		t.Fatalf("Verify() = %+v, %v, want 3 intact entries", report, err)
+	}
+
+	info, err := os.Stat(p.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); runtime.GOOS != "windows" && perm&0077 != 0 {
+		t.Errorf("key permissions = %v, want owner only", perm)
+	}
+}
+
+func TestVerifyEmpty(t *testing.T) {
+	p := newLog(t, 0)
+	if report, err := Verify(p.log, p.head, p.key); err != nil || report.Entries != 0 {
+		t.Fatalf("Verify() = %+v, %v, want an empty intact log", report, err)
+	}
+}
+
+func TestVerifyDetectsEdit(t *testing.T) {
+	p := newLog(t, 3)
+	lines := readLines(t, p.log)
+	lines[1] = bytes.Replace(lines[1], []byte(`"doh"`), []byte(`"dot"`), 1)
+	writeLines(t, p.log, lines)
+
+	if _, err := Verify(p.log, p.head, p.key); !errors.Is(err, ErrTampered) {
+		t.Fatalf("Verify() error = %v, want %v", err, ErrTampered)
+	}
+}
+
+// Without the key, the chain values in the log do not give away a valid head
+func TestVerifyDetectsTruncationWithForgedHead(t *testing.T) {
+	p := newLog(t, 3)
+	lines := readLines(t, p.log)
+
+	var removed Entry
+	if err := json.Unmarshal(bytes.TrimSpace(lines[2]), &removed); err != nil {
+		t.Fatal(err)
+	}
+	writeLines(t, p.log, lines[:2])
+	forged, _ := json.Marshal(head{Seq: 2, Hash: removed.Prev})
+	if err := os.WriteFile(p.head, forged, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Verify(p.log, p.head, p.key); !errors.Is(err, ErrTampered) {
+		t.Fatalf("Verify() error = %v, want %v", err, ErrTampered)
+	}
+}
+
+func TestVerifyHeadLagging(t *testing.T) {
+	p := newLog(t, 2)
+	before, err := os.ReadFile(p.head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Append(p.log, p.head, p.key, Entry{Action: "start"}); err != nil {
+		t.Fatal(err)
+	}
+	// As if the process died between writing the entry and the head
+	if err := os.WriteFile(p.head, before, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Verify(p.log, p.head, p.key)
+	if err != nil || report.Entries != 3 || !report.HeadLagging {
+		t.Fatalf("Verify() = %+v, %v, want 3 entries with a lagging head", report, err)
+	}
+}
+
+func TestMissingKey(t *testing.T) {
+	p := newLog(t, 2)
+	if err := os.Remove(p.key); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Verify(p.log, p.head, p.key); !errors.Is(err, ErrTampered) {
+		t.Errorf("Verify() error = %v, want %v", err, ErrTampered)
+	}
+	if err := Append(p.log, p.head, p.key, Entry{Action: "start"}); !errors.Is(err, ErrTampered) {
+		t.Errorf("Append() error = %v, want %v", err, ErrTampered)
+	}
+	if _, err := os.Stat(p.key); !errors.Is(err, os.ErrNotExist) {
+		t.Error("Append() created a new key for an existing log")
+	}
+}
+
+func TestVerifyWithOtherKey(t *testing.T) {
+	p := newLog(t, 2)
+	other := newLog(t, 1)
+
+	if _, err := Verify(p.log, p.head, other.key); !errors.Is(err, ErrTampered) {
+		t.Fatalf("Verify() error = %v, want %v", err, ErrTampered)
+	}
+}
+
+func TestVerifyDetectsDeletedLog(t *testing.T) {
+	p := newLog(t, 2)
+	if err := os.Remove(p.log); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(p.head); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(p.log, p.head, p.key); !errors.Is(err, ErrTampered) {
+		t.Errorf("Verify() error = %v, want %v for a log deleted without its key", err, ErrTampered)
+	}
+
+	if err := os.Remove(p.key); err != nil {
+		t.Fatal(err)
+	}
+	if report, err := Verify(p.log, p.head, p.key); err != nil || report.Entries != 0 {
+		t.Errorf("Verify() = %+v, %v, want no entries left for the caller to report", report, err)
+	}
+}
+
+func TestRecordInstall(t *testing.T) {
+	t.Setenv("EZFORCE_APP_INSTALLPATH", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if err := config.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RecordInstall(); err != nil {
+		t.Fatalf("RecordInstall() error = %v", err)
+	}
+	report, err := Verify(Path(), HeadPath(), KeyPath())
+	if err != nil || report.Entries != 1 {
+		t.Fatalf("Verify() = %+v, %v, want the install entry", report, err)
+	}
+	if lines := readLines(t, Path()); !bytes.Contains(lines[0], []byte(`"action":"install"`)) {
+		t.Errorf("first entry = %s, want the install", lines[0])
+	}
+}
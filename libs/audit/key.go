@@ -0,0 +1,87 @@
+package audit
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ezydark/ezforce/app/config"
+)
+
+// Name of the secret key authenticating the audit log, next to the install path.
+// Only SYSTEM, the administrators or root can read it, so users without admin
+// rights can neither forge entries nor move the head to hide the ones they cut off.
+// It does not hold off an admin, who can read the key and rewrite the log.
+const KeyFileName = "audit.key"
+
+// Size of the key in bytes
+const keySize = 32
+
+// Path of the key of the audit log
+func KeyPath() string {
+	return filepath.Join(config.App().InstallPath, KeyFileName)
+}
+
+// Read the key authenticating the audit log
+func readKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != keySize {
+		return nil, fmt.Errorf("%w: key is not valid", ErrTampered)
+	}
+	return key, nil
+}
+
+// Create a random key, restricted to SYSTEM, the administrators or root before it is written
+func createKey(path string) ([]byte, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("could not generate audit key:\n %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("could not create audit key directory:\n %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("could not create audit key:\n %w", err)
+	}
+	err = restrictKey(path)
+	if err == nil {
+		_, err = f.WriteString(hex.EncodeToString(key) + "\n")
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("could not write audit key:\n %w", err)
+	}
+	return key, nil
+}
+
+// Hex HMAC-SHA256 of a line, chaining the next entry to it
+func chainMAC(key []byte, line []byte) string {
+	return mac(key, "chain", line)
+}
+
+// Hex HMAC-SHA256 of the last line stored in the head. It differs from the
+// chain value kept in the log, so the log cannot be used to forge a head.
+func headMAC(key []byte, line []byte) string {
+	return mac(key, "head", line)
+}
+
+func mac(key []byte, purpose string, line []byte) string {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(purpose + "\n"))
+	h.Write(line)
+	return hex.EncodeToString(h.Sum(nil))
+}
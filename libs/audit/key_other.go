@@ -0,0 +1,10 @@
+//go:build !windows
+
+package audit
+
+import "os"
+
+// Let only root read and write the key
+func restrictKey(path string) error {
+	return os.Chmod(path, 0600)
+}
@@ -0,0 +1,23 @@
+package audit
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+// Full access for SYSTEM and the administrators only, not inherited from the install path.
+// The administrators keep access to verify the log, 'audit verify' runs as one.
+const keySDDL = "D:P(A;;FA;;;SY)(A;;FA;;;BA)"
+
+// Let only SYSTEM and the administrators read and write the key
+func restrictKey(path string) error {
+	sd, err := windows.SecurityDescriptorFromString(keySDDL)
+	if err != nil {
+		return err
+	}
+	dacl, _, err := sd.DACL()
+	if err != nil {
+		return err
+	}
+	return windows.SetNamedSecurityInfo(path, windows.SE_FILE_OBJECT,
+		windows.DACL_SECURITY_INFORMATION|windows.PROTECTED_DACL_SECURITY_INFORMATION, nil, nil, dacl, nil)
+}
@@ -0,0 +1,196 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ezydark/ezforce/libs/wait"
+)
+
+// Enforcement step an event belongs to, in pipeline order
+type Step string
+
+const (
+	StepInstalled     Step = "installed"
+	StepServiceConfig Step = "service-config"
+	StepEnabled       Step = "enabled"
+	StepRunning       Step = "running"
+	StepRecovery      Step = "recovery"
+	StepConnected     Step = "connected"
+	StepMode          Step = "mode"
+	StepFamilies      Step = "families"
+)
+
+// What an Ensure* function did about the observed state
+type Action string
+
+const (
+	// The observed state already was the desired one
+	ActionNone          Action = "none"
+	ActionEnable        Action = "enable"
+	ActionStart         Action = "start"
+	ActionConnect       Action = "connect"
+	ActionSetMode       Action = "set-mode"
+	ActionSetFamilies   Action = "set-families"
+	ActionSetRecovery   Action = "set-recovery"
+	ActionRestoreConfig Action = "restore-config"
+)
+
+// Where an Ensure* function failed
+type ErrorClass string
+
+const (
+	ErrorNone ErrorClass = ""
+	// The current state could not be observed
+	ErrorQuery ErrorClass = "query"
+	// The corrective action failed
+	ErrorAction ErrorClass = "action"
+	// The state still differs after the action
+	ErrorVerify ErrorClass = "verify"
+	// Waiting for the state to change took too long
+	ErrorTimeout ErrorClass = "timeout"
+	// The enforcement was stopped
+	ErrorCanceled ErrorClass = "canceled"
+)
+
+// Outcome of one Ensure* call
+type Event struct {
+	Time      time.Time
+	Component string
+	Step      Step
+	Observed  string
+	Desired   string
+	Action    Action
+	Duration  time.Duration
+	Err       error
+	ErrClass  ErrorClass
+}
+
+// Whether the desired state was restored
+func (e Event) Corrected() bool {
+	return e.Action != ActionNone && e.Err == nil
+}
+
+// Outcome of an Ensure* call as logged, e.g. "corrected"
+type Result string
+
+const (
+	// Already in the desired state
+	ResultOK        Result = "ok"
+	ResultCorrected Result = "corrected"
+	ResultFailed    Result = "failed"
+)
+
+func (e Event) Result() Result {
+	switch {
+	case e.Err != nil:
+		return ResultFailed
+	case e.Action != ActionNone:
+		return ResultCorrected
+	default:
+		return ResultOK
+	}
+}
+
+// Receives every emitted event, e.g. the log, the audit trail or metrics
+type Sink interface {
+	Emit(event Event)
+}
+
+var (
+	mu    sync.RWMutex
+	sinks []Sink
+)
+
+// Send the events to the sinks from now on
+func Register(s ...Sink) {
+	mu.Lock()
+	defer mu.Unlock()
+	sinks = append(sinks, s...)
+}
+
+// Remove every registered sink
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	sinks = nil
+}
+
+// Send an event to every registered sink
+func Emit(event Event) {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, sink := range sinks {
+		sink.Emit(event)
+	}
+}
+
+// Phase of an Ensure* call, deciding the class of its error
+type phase int
+
+const (
+	phaseQuery phase = iota
+	phaseAction
+	phaseVerify
+)
+
+// Event being built while an Ensure* function runs
+type Tracker struct {
+	Event
+	phase phase
+}
+
+// Start the event of an Ensure* call, which is emitted by End
+func Begin(component string, step Step, desired string) *Tracker {
+	return &Tracker{Event: Event{
+		Time:      time.Now(),
+		Component: component,
+		Step:      step,
+		Desired:   desired,
+		Action:    ActionNone,
+	}}
+}
+
+// Record the state found
+func (t *Tracker) Observe(observed string) {
+	t.Observed = observed
+}
+
+// Record the corrective action about to be taken
+func (t *Tracker) Act(action Action) {
+	t.Action = action
+	t.phase = phaseAction
+}
+
+// Record that the action was taken and its result is being checked
+func (t *Tracker) Verify() {
+	t.phase = phaseVerify
+}
+
+// Classify the error, measure the duration and emit the event
+func (t *Tracker) End(err error) {
+	t.Duration = time.Since(t.Time)
+	t.Err = err
+	t.ErrClass = classify(err, t.phase)
+	Emit(t.Event)
+}
+
+// Class of an error returned during the given phase
+func classify(err error, p phase) ErrorClass {
+	switch {
+	case err == nil:
+		return ErrorNone
+	case errors.Is(err, wait.ErrTimeout), errors.Is(err, wait.ErrExhausted), errors.Is(err, context.DeadlineExceeded):
+		return ErrorTimeout
+	case errors.Is(err, context.Canceled):
+		return ErrorCanceled
+	case p == phaseAction:
+		return ErrorAction
+	case p == phaseVerify:
+		return ErrorVerify
+	default:
+		return ErrorQuery
+	}
+}
@@ -0,0 +1,72 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ezydark/ezforce/libs/wait"
+)
+
+func TestClassify(t *testing.T) {
+	failure := errors.New("failure")
+	tests := []struct {
+		err   error
+		phase phase
+		want  ErrorClass
+	}{
+		{nil, phaseAction, ErrorNone},
+		{failure, phaseQuery, ErrorQuery},
+		{failure, phaseAction, ErrorAction},
+		{failure, phaseVerify, ErrorVerify},
+		{fmt.Errorf("wrapped:\n %w", wait.ErrTimeout), phaseAction, ErrorTimeout},
+		{fmt.Errorf("wrapped:\n %w", wait.ErrExhausted), phaseAction, ErrorTimeout},
+		{context.DeadlineExceeded, phaseQuery, ErrorTimeout},
+		{fmt.Errorf("wrapped:\n %w", context.Canceled), phaseAction, ErrorCanceled},
+	}
+	for _, tt := range tests {
+		if got := classify(tt.err, tt.phase); got != tt.want {
+			t.Errorf("classify(%v, %d) = %q, want %q", tt.err, tt.phase, got, tt.want)
+		}
+	}
+}
+
+func TestTrackerEmits(t *testing.T) {
+	recorder := &Recorder{}
+	Register(recorder)
+	defer Reset()
+
+	ev := Begin("warp", StepMode, "warp")
+	ev.Observe("doh")
+	ev.Act(ActionSetMode)
+	ev.Verify()
+	ev.End(nil)
+
+	emitted := recorder.Events()
+	if len(emitted) != 1 {
+		t.Fatalf("emitted %d events, want 1", len(emitted))
+	}
+	e := emitted[0]
+	if e.Component != "warp" || e.Step != StepMode || e.Observed != "doh" || e.Desired != "warp" ||
+		e.Action != ActionSetMode || e.ErrClass != ErrorNone || !e.Corrected() {
+		t.Errorf("event = %+v, want a corrected mode", e)
+	}
+}
+
+func TestEventResult(t *testing.T) {
+	tests := []struct {
+		event Event
+		want  Result
+	}{
+		{Event{Action: ActionNone}, ResultOK},
+		{Event{Action: ActionStart}, ResultCorrected},
+		{Event{Action: ActionStart, Err: errors.New("failure")}, ResultFailed},
+		{Event{Action: ActionNone, Err: errors.New("failure")}, ResultFailed},
+	}
+	for _, tt := range tests {
+		if got := tt.event.Result(); got != tt.want {
+			t.Errorf("Result() of %+v = %q, want %q", tt.event, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Package eventstest checks the events emitted by the Ensure* functions in tests
+package eventstest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ezydark/ezforce/libs/events"
+)
+
+// Failure injected into a fake
+var ErrFake = errors.New("fake failure")
+
+// Ensure* call against a fake of type F, expected to emit a single event
+type Case[F any] struct {
+	Name string
+	// Prepare the fake, which starts in the desired state
+	Setup    func(f F)
+	Observed string
+	Action   events.Action
+	ErrClass events.ErrorClass
+}
+
+// Record the events emitted while the test runs
+func Record(t testing.TB) *events.Recorder {
+	t.Helper()
+	recorder := &events.Recorder{}
+	events.Register(recorder)
+	t.Cleanup(events.Reset)
+	return recorder
+}
+
+// Check that the call failed as the case expects and emitted its single event
+func Check[F any](t testing.TB, recorder *events.Recorder, err error, component string, step events.Step, desired string, tt Case[F]) {
+	t.Helper()
+	if (err != nil) != (tt.ErrClass != events.ErrorNone) {
+		t.Fatalf("error = %v, want class %q", err, tt.ErrClass)
+	}
+
+	emitted := recorder.Events()
+	if len(emitted) != 1 {
+		t.Fatalf("emitted %d events, want 1", len(emitted))
+	}
+	e := emitted[0]
+	if e.Step != step || e.Component != component {
+		t.Errorf("event of %s/%s, want %s/%s", e.Component, e.Step, component, step)
+	}
+	if e.Observed != tt.Observed || e.Desired != desired {
+		t.Errorf("observed %q desired %q, want %q and %q", e.Observed, e.Desired, tt.Observed, desired)
+	}
+	if e.Action != tt.Action || e.ErrClass != tt.ErrClass {
+		t.Errorf("action %q class %q, want %q and %q", e.Action, e.ErrClass, tt.Action, tt.ErrClass)
+	}
+	if !errors.Is(e.Err, err) {
+		t.Errorf("event error = %v, want %v", e.Err, err)
+	}
+}
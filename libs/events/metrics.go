@@ -0,0 +1,101 @@
+package events
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Sink counting the events per step, action and error class, written in the
+// Prometheus text format, e.g. for the textfile collector of node_exporter
+type Metrics struct {
+	mu        sync.Mutex
+	counts    map[metricKey]uint64
+	durations map[Step]float64
+}
+
+type metricKey struct {
+	step     Step
+	action   Action
+	errClass ErrorClass
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{counts: make(map[metricKey]uint64), durations: make(map[Step]float64)}
+}
+
+func (m *Metrics) Emit(e Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counts[metricKey{e.Step, e.Action, e.ErrClass}]++
+	m.durations[e.Step] += e.Duration.Seconds()
+}
+
+// Number of events of a step with the given action and error class
+func (m *Metrics) Count(step Step, action Action, errClass ErrorClass) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counts[metricKey{step, action, errClass}]
+}
+
+// Write the counters in the Prometheus text format, sorted so the output is stable
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+	b.WriteString("# HELP ezforce_enforcement_events_total Enforcement events by step, action and error class.\n")
+	b.WriteString("# TYPE ezforce_enforcement_events_total counter\n")
+	keys := make([]metricKey, 0, len(m.counts))
+	for key := range m.counts {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b metricKey) int {
+		return strings.Compare(
+			string(a.step)+"\x00"+string(a.action)+"\x00"+string(a.errClass),
+			string(b.step)+"\x00"+string(b.action)+"\x00"+string(b.errClass))
+	})
+	for _, key := range keys {
+		fmt.Fprintf(&b, "ezforce_enforcement_events_total{step=%q,action=%q,error_class=%q} %d\n",
+			key.step, key.action, key.errClass, m.counts[key])
+	}
+
+	b.WriteString("# HELP ezforce_enforcement_duration_seconds_total Time spent in each enforcement step.\n")
+	b.WriteString("# TYPE ezforce_enforcement_duration_seconds_total counter\n")
+	steps := make([]Step, 0, len(m.durations))
+	for step := range m.durations {
+		steps = append(steps, step)
+	}
+	slices.Sort(steps)
+	for _, step := range steps {
+		fmt.Fprintf(&b, "ezforce_enforcement_duration_seconds_total{step=%q} %g\n", step, m.durations[step])
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Replace the file at path with the counters, atomically so collectors never read half of it
+func (m *Metrics) WriteFile(path string) error {
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("could not create metrics file:\n %w", err)
+	}
+	err = m.WritePrometheus(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("could not write metrics file:\n %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package events
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden files")
+
+func TestWritePrometheus(t *testing.T) {
+	m := NewMetrics()
+	for _, e := range []Event{
+		{Step: StepRunning, Action: ActionNone, Duration: 250 * time.Millisecond},
+		{Step: StepConnected, Action: ActionConnect, Duration: 2 * time.Second},
+		{Step: StepRunning, Action: ActionStart, ErrClass: ErrorTimeout, Duration: 30 * time.Second},
+		{Step: StepRunning, Action: ActionNone, Duration: 250 * time.Millisecond},
+		{Step: StepMode, Action: ActionSetMode, ErrClass: ErrorVerify, Duration: 1500 * time.Millisecond},
+		{Step: StepConnected, Action: ActionNone, ErrClass: ErrorQuery, Duration: 100 * time.Millisecond},
+	} {
+		m.Emit(e)
+	}
+
+	var got bytes.Buffer
+	if err := m.WritePrometheus(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	golden := filepath.Join("testdata", "metrics.prom")
+	if *update {
+		if err := os.WriteFile(golden, got.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("WritePrometheus() differs from %s, rerun with -update if intended:\n%s", golden, got.String())
+	}
+
+	if n := m.Count(StepRunning, ActionNone, ErrorNone); n != 2 {
+		t.Errorf("Count() = %d, want 2", n)
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	m := NewMetrics()
+	m.Emit(Event{Step: StepFamilies, Action: ActionSetFamilies})
+
+	path := filepath.Join(t.TempDir(), "metrics.prom")
+	if err := m.WriteFile(path); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	var want bytes.Buffer
+	m.WritePrometheus(&want)
+	got, err := os.ReadFile(path)
+	if err != nil || !bytes.Equal(got, want.Bytes()) {
+		t.Errorf("metrics file = %q, %v, want %q", got, err, want.String())
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d files, want the metrics file only", len(entries))
+	}
+}
@@ -0,0 +1,60 @@
+package events
+
+import (
+	"sync"
+
+	"github.com/ezydark/ezforce/libs/logger"
+)
+
+// Sink logging every event with its fields, tagged with the component that emitted it
+type LogSink struct{}
+
+func (LogSink) Emit(e Event) {
+	log := logger.Component(e.Component)
+
+	entry := log.Debug()
+	message := "Enforcement step already in desired state"
+	switch {
+	case e.Err != nil:
+		entry = log.Error().Str("error_class", string(e.ErrClass)).Err(e.Err)
+		message = "Enforcement step failed"
+	case e.Action != ActionNone:
+		entry = log.Warn()
+		message = "Enforcement step corrected"
+	}
+
+	entry.
+		Str("step", string(e.Step)).
+		Str("result", string(e.Result())).
+		Str("observed", e.Observed).
+		Str("desired", e.Desired).
+		Str("action", string(e.Action)).
+		Dur("duration", e.Duration).
+		Msg(message)
+}
+
+// Sink keeping the events in memory, to check what Ensure* functions emitted
+type Recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *Recorder) Emit(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+// Events received so far, oldest first
+func (r *Recorder) Events() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event(nil), r.events...)
+}
+
+// Forget the received events
+func (r *Recorder) Clear() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = nil
+}
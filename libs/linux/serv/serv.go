@@ -0,0 +1,239 @@
+//go:build linux
+
+package serv
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/ezydark/ezforce/app/config"
+	"github.com/ezydark/ezforce/app/enforce"
+	"github.com/ezydark/ezforce/libs/audit"
+	"github.com/ezydark/ezforce/libs/warp"
+	"github.com/rs/zerolog/log"
+)
+
+// Returned when the ezForce service is not installed
+var ErrNotInstalled = errors.New("service is not installed")
+
+// Check if the process was started by systemd as a service
+func IsService() (bool, error) {
+	// Set by systemd for every unit it starts
+	_, found := os.LookupEnv("INVOCATION_ID")
+	return found && os.Getppid() == 1, nil
+}
+
+// Run as the ezForce service until systemd stops it
+func Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Info().Msg("Service started")
+	enforce.Loop(ctx)
+	log.Info().Msg("Service stopping")
+	return nil
+}
+
+// Run the service logic on the console, systemd services have nothing to emulate
+func Debug() error {
+	return Run()
+}
+
+// Install ezForce as a hardened, automatically starting systemd unit running 'ezforce run'
+func Install() error {
+	unitPath := UnitPath(config.App())
+	if _, err := os.Stat(unitPath); err == nil {
+		return fmt.Errorf("service %s already exists", config.App().ServiceName)
+	}
+
+	// The unit runs ezForce from the install path
+	err := installExecutable(ExecPath(config.App()))
+	if err != nil {
+		return err
+	}
+
+	// Set up before writing the unit, so that a failure leaves nothing behind that would fail a retry
+	err = prepareInstall()
+	if err != nil {
+		return err
+	}
+
+	// The unit can only write to drop-in directories that exist when it starts
+	err = os.MkdirAll(DropInDir(config.Warp()), 0755)
+	if err != nil {
+		return fmt.Errorf("could not create Warp drop-in directory: %v", err)
+	}
+
+	err = os.WriteFile(unitPath, []byte(Unit(config.App(), config.Warp())), 0644)
+	if err != nil {
+		return fmt.Errorf("could not write unit file: %v", err)
+	}
+
+	if _, err = systemctl("daemon-reload"); err != nil {
+		os.Remove(unitPath)
+		return err
+	}
+	if _, err = systemctl("enable", UnitName(config.App().ServiceName)); err != nil {
+		os.Remove(unitPath)
+		systemctl("daemon-reload")
+		return err
+	}
+
+	return nil
+}
+
+// Snapshot the known-good Warp service config, require signed config files and start the audit log
+func prepareInstall() error {
+	warpServ, err := warp.Serv.Init()
+	if err != nil {
+		return fmt.Errorf("could not open Warp service: %v", err)
+	}
+	defer warpServ.Close()
+	_, err = warpServ.SaveBaseline()
+	if err != nil {
+		return fmt.Errorf("could not save Warp service baseline: %v", err)
+	}
+
+	// Removing the pinned public key must not turn the signature checks off
+	err = config.RequireSigning()
+	if err != nil {
+		return fmt.Errorf("could not require signed config files: %v", err)
+	}
+
+	// From now on a missing audit log was deleted
+	err = audit.RecordInstall()
+	if err != nil {
+		return fmt.Errorf("could not record the install in the audit log: %v", err)
+	}
+	return nil
+}
+
+// Copy the running executable to the path the unit runs, unless it already runs from there
+func installExecutable(path string) error {
+	exePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("could not get executable path: %v", err)
+	}
+	exePath, err = filepath.EvalSymlinks(exePath)
+	if err != nil {
+		return fmt.Errorf("could not get executable path: %v", err)
+	}
+	if exePath == path {
+		return nil
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return fmt.Errorf("could not create install directory: %v", err)
+	}
+
+	src, err := os.Open(exePath)
+	if err != nil {
+		return fmt.Errorf("could not open executable: %v", err)
+	}
+	defer src.Close()
+
+	// Replace atomically so a running service keeps its old binary
+	tmp := path + ".tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return fmt.Errorf("could not create executable: %v", err)
+	}
+	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("could not install executable to '%s': %v", path, err)
+	}
+	return nil
+}
+
+// Stop, disable and remove the ezForce unit
+func Remove() error {
+	unitPath := UnitPath(config.App())
+	if _, err := os.Stat(unitPath); err != nil {
+		return fmt.Errorf("service %s: %w", config.App().ServiceName, ErrNotInstalled)
+	}
+
+	_, err := systemctl("disable", "--now", UnitName(config.App().ServiceName))
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(unitPath)
+	if err != nil {
+		return fmt.Errorf("could not remove unit file: %v", err)
+	}
+
+	_, err = systemctl("daemon-reload")
+	return err
+}
+
+func Start() error {
+	_, err := systemctl("start", UnitName(config.App().ServiceName))
+	return err
+}
+
+func Stop() error {
+	_, err := systemctl("stop", UnitName(config.App().ServiceName))
+	return err
+}
+
+// Current state of the ezForce service, e.g. "Running"
+func Status() (string, error) {
+	out, err := systemctl("show", UnitName(config.App().ServiceName), "-p", "LoadState", "-p", "ActiveState")
+	if err != nil {
+		return "", err
+	}
+
+	props := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		key, value, found := strings.Cut(line, "=")
+		if found {
+			props[key] = strings.TrimSpace(value)
+		}
+	}
+	if props["LoadState"] == "not-found" {
+		return "", fmt.Errorf("service %s: %w", config.App().ServiceName, ErrNotInstalled)
+	}
+
+	switch props["ActiveState"] {
+	case "active", "reloading":
+		return "Running", nil
+	case "activating":
+		return "StartPending", nil
+	case "deactivating":
+		return "StopPending", nil
+	case "inactive", "failed":
+		return "Stopped", nil
+	default:
+		return "Unknown", nil
+	}
+}
+
+func systemctl(args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("systemctl", args...)
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("'systemctl %s' failed: %s: %v",
+			strings.Join(args, " "), strings.TrimSpace(stderr.String()), err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,100 @@
+package serv
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ezydark/ezforce/app/config"
+)
+
+// Directory holding the admin's unit files
+const UnitDir = "/etc/systemd/system"
+
+const serviceDescription = "ezForce - Enforcer preventing social media addiction from affecting productivity"
+
+// Name of the systemd unit of a service, ".service" is appended when missing
+func UnitName(serviceName string) string {
+	if !strings.Contains(serviceName, ".") {
+		serviceName += ".service"
+	}
+	return serviceName
+}
+
+// Path of the unit file of the ezForce service
+func UnitPath(app *config.AppConfig) string {
+	return filepath.Join(UnitDir, UnitName(app.ServiceName))
+}
+
+// Directory of the Warp unit's drop-ins, which the ezForce service writes
+func DropInDir(warp *config.WarpConfig) string {
+	return filepath.Join(UnitDir, UnitName(warp.ServiceName)+".d")
+}
+
+// Path the ezForce service runs from
+func ExecPath(app *config.AppConfig) string {
+	return filepath.Join(app.InstallPath, app.ExecName)
+}
+
+// Hardened unit file running 'ezforce run' from the install path.
+// The text only depends on the given configs, so it can be compared as a whole.
+func Unit(app *config.AppConfig, warp *config.WarpConfig) string {
+	warpUnit := UnitName(warp.ServiceName)
+
+	var b strings.Builder
+	b.WriteString("# Generated by ezForce, reinstall the service to update it\n")
+	b.WriteString("[Unit]\n")
+	fmt.Fprintf(&b, "Description=%s\n", serviceDescription)
+	b.WriteString("Wants=network-online.target\n")
+	fmt.Fprintf(&b, "After=network-online.target %s\n", warpUnit)
+	// Never give up restarting
+	b.WriteString("StartLimitIntervalSec=0\n")
+
+	b.WriteString("\n[Service]\n")
+	b.WriteString("Type=simple\n")
+	fmt.Fprintf(&b, "ExecStart=%s run\n", quote(ExecPath(app)))
+	fmt.Fprintf(&b, "WorkingDirectory=%s\n", escapeSpecifiers(app.InstallPath))
+	b.WriteString("Restart=always\n")
+	b.WriteString("RestartSec=5s\n")
+	fmt.Fprintf(&b, "SyslogIdentifier=%s\n", app.ServiceName)
+	if app.Guardian {
+		// Leave the guardian running when the service is stopped, so it can restart it
+		b.WriteString("KillMode=process\n")
+	}
+
+	// Root is needed to manage the Warp unit, so confine everything else
+	b.WriteString("NoNewPrivileges=yes\n")
+	b.WriteString("ProtectSystem=strict\n")
+	// Logs, status and baselines, and the drop-ins of the Warp unit
+	fmt.Fprintf(&b, "ReadWritePaths=%s %s\n", quote(app.InstallPath), quote(DropInDir(warp)))
+	b.WriteString("ProtectHome=read-only\n")
+	b.WriteString("PrivateTmp=yes\n")
+	b.WriteString("ProtectKernelTunables=yes\n")
+	b.WriteString("ProtectKernelModules=yes\n")
+	b.WriteString("ProtectKernelLogs=yes\n")
+	b.WriteString("ProtectControlGroups=yes\n")
+	b.WriteString("ProtectClock=yes\n")
+	b.WriteString("ProtectHostname=yes\n")
+	b.WriteString("RestrictAddressFamilies=AF_UNIX AF_INET AF_INET6 AF_NETLINK\n")
+	b.WriteString("RestrictNamespaces=yes\n")
+	b.WriteString("RestrictRealtime=yes\n")
+	b.WriteString("RestrictSUIDSGID=yes\n")
+	b.WriteString("LockPersonality=yes\n")
+	b.WriteString("MemoryDenyWriteExecute=yes\n")
+	b.WriteString("SystemCallArchitectures=native\n")
+
+	b.WriteString("\n[Install]\n")
+	b.WriteString("WantedBy=multi-user.target\n")
+	return b.String()
+}
+
+// Quote a path as a single word of a unit setting, so spaces do not split it
+func quote(path string) string {
+	path = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(path)
+	return `"` + escapeSpecifiers(path) + `"`
+}
+
+// Keep systemd from expanding the '%' of a path as a specifier
+func escapeSpecifiers(path string) string {
+	return strings.ReplaceAll(path, "%", "%%")
+}
@@ -0,0 +1,50 @@
+package serv
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ezydark/ezforce/app/config"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden files")
+
+func TestUnit(t *testing.T) {
+	app := &config.AppConfig{InstallPath: "/opt/ezforce", ExecName: "ezforce", ServiceName: "ezforce"}
+	warp := &config.WarpConfig{ServiceName: "warp-svc"}
+	got := Unit(app, warp)
+
+	golden := filepath.Join("testdata", "ezforce.service")
+	if *update {
+		if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("Unit() differs from %s, rerun with -update if intended:\n%s", golden, got)
+	}
+}
+
+func TestUnitQuotesPaths(t *testing.T) {
+	app := &config.AppConfig{InstallPath: `/opt/ez force/100%"`, ExecName: "ezforce", ServiceName: "ezforce", Guardian: true}
+	warp := &config.WarpConfig{ServiceName: "warp-svc"}
+	unit := Unit(app, warp)
+
+	for _, line := range []string{
+		`ExecStart="/opt/ez force/100%%\"/ezforce" run` + "\n",
+		`WorkingDirectory=/opt/ez force/100%%"` + "\n",
+		`ReadWritePaths="/opt/ez force/100%%\"" "/etc/systemd/system/warp-svc.service.d"` + "\n",
+		"KillMode=process\n",
+	} {
+		if !strings.Contains(unit, line) {
+			t.Errorf("unit without %q:\n%s", line, unit)
+		}
+	}
+}
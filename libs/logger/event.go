@@ -0,0 +1,81 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/rs/zerolog"
+)
+
+// Field of a zerolog JSON event
+type field struct {
+	Key   string
+	Value json.RawMessage
+}
+
+// Fields of a zerolog JSON event in the order they were logged
+func decodeEvent(p []byte) ([]field, error) {
+	decoder := json.NewDecoder(bytes.NewReader(p))
+	decoder.UseNumber()
+
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, fmt.Errorf("log event is not a JSON object: %s", p)
+	}
+
+	var fields []field
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("invalid log event: %w", err)
+		}
+		key, _ := token.(string)
+
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, fmt.Errorf("invalid log event: %w", err)
+		}
+		fields = append(fields, field{Key: key, Value: value})
+	}
+	return fields, nil
+}
+
+// Value of a field as text, strings are unquoted and anything else is kept as JSON
+func (f field) String() string {
+	var s string
+	if err := json.Unmarshal(f.Value, &s); err != nil {
+		return string(f.Value)
+	}
+	return s
+}
+
+// Level of the event, zerolog.NoLevel when it has none
+func eventLevel(fields []field) zerolog.Level {
+	for _, f := range fields {
+		if f.Key == zerolog.LevelFieldName {
+			level, err := zerolog.ParseLevel(f.String())
+			if err == nil {
+				return level
+			}
+		}
+	}
+	return zerolog.NoLevel
+}
+
+// Syslog severity of a level, as used by syslog and journald
+func severity(level zerolog.Level) int {
+	switch level {
+	case zerolog.PanicLevel:
+		return 0 // Emergency
+	case zerolog.FatalLevel:
+		return 2 // Critical
+	case zerolog.ErrorLevel:
+		return 3 // Error
+	case zerolog.WarnLevel:
+		return 4 // Warning
+	case zerolog.InfoLevel, zerolog.NoLevel:
+		return 6 // Informational
+	default:
+		return 7 // Debug
+	}
+}
@@ -0,0 +1,99 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// Socket of the journald native protocol
+var JournaldSocket = "/run/systemd/journal/socket"
+
+// Writer sending the JSON events of zerolog to journald over its native protocol.
+// Every event field becomes an upper case journal field, e.g. COMPONENT or STEP.
+type JournaldWriter struct {
+	Identifier string
+	conn       *net.UnixConn
+}
+
+// Connect to the journald socket, tagging the entries with the identifier
+func NewJournaldWriter(identifier string) (*JournaldWriter, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: JournaldSocket, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to journald:\n %w", err)
+	}
+	return &JournaldWriter{Identifier: identifier, conn: conn}, nil
+}
+
+// Check if stdout and stderr are connected to the journal, so the console already lands there
+func IsJournalStream() bool {
+	_, found := os.LookupEnv("JOURNAL_STREAM")
+	return found
+}
+
+func (w *JournaldWriter) Write(p []byte) (int, error) {
+	fields, err := decodeEvent(p)
+	if err != nil {
+		return 0, err
+	}
+
+	var entry bytes.Buffer
+	writeJournalField(&entry, "PRIORITY", strconv.Itoa(severity(eventLevel(fields))))
+	writeJournalField(&entry, "SYSLOG_IDENTIFIER", w.Identifier)
+	for _, f := range fields {
+		switch f.Key {
+		case zerolog.LevelFieldName, zerolog.TimestampFieldName:
+			// journald keeps its own priority and timestamp
+		case zerolog.MessageFieldName:
+			writeJournalField(&entry, "MESSAGE", f.String())
+		default:
+			writeJournalField(&entry, journalFieldName(f.Key), f.String())
+		}
+	}
+
+	if _, err := w.conn.Write(entry.Bytes()); err != nil {
+		return 0, fmt.Errorf("could not write to journald:\n %w", err)
+	}
+	return len(p), nil
+}
+
+func (w *JournaldWriter) Close() error {
+	return w.conn.Close()
+}
+
+// Append a field, values spanning lines are length prefixed as the protocol requires
+func writeJournalField(b *bytes.Buffer, name string, value string) {
+	b.WriteString(name)
+	if !strings.Contains(value, "\n") {
+		b.WriteByte('=')
+		b.WriteString(value)
+		b.WriteByte('\n')
+		return
+	}
+	b.WriteByte('\n')
+	binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
+
+// Journal field names only consist of upper case letters, digits and underscores,
+// and must not start with an underscore or a digit
+func journalFieldName(key string) string {
+	name := []byte(strings.ToUpper(key))
+	for i, c := range name {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			name[i] = '_'
+		}
+	}
+	s := strings.TrimLeft(string(name), "_0123456789")
+	if s == "" {
+		return "FIELD"
+	}
+	return s
+}
@@ -0,0 +1,74 @@
+package logger
+
+import (
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJournaldWriter(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "journal.socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	old := JournaldSocket
+	JournaldSocket = socket
+	t.Cleanup(func() { JournaldSocket = old })
+
+	w, err := NewJournaldWriter("ezforce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	event := `{"level":"error","component":"warp/serv","step":"running","result":"failed","error":"exit 1\ndetails","time":"2026-01-02T03:04:05Z","message":"Enforcement step failed"}`
+	if _, err := w.Write([]byte(event)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Multi-line values are the name, a newline, the little endian 64 bit length and the value
+	multiLine := []byte("ERROR\n")
+	multiLine = binary.LittleEndian.AppendUint64(multiLine, uint64(len("exit 1\ndetails")))
+	multiLine = append(multiLine, "exit 1\ndetails\n"...)
+
+	want := "PRIORITY=3\n" +
+		"SYSLOG_IDENTIFIER=ezforce\n" +
+		"COMPONENT=warp/serv\n" +
+		"STEP=running\n" +
+		"RESULT=failed\n" +
+		string(multiLine) +
+		"MESSAGE=Enforcement step failed\n"
+	if got := string(buf[:n]); got != want {
+		t.Errorf("journal entry = %q, want %q", got, want)
+	}
+	if strings.Contains(string(buf[:n]), "TIME=") {
+		t.Error("journal entry repeats the timestamp journald keeps itself")
+	}
+}
+
+func TestJournalFieldName(t *testing.T) {
+	tests := map[string]string{
+		"component":   "COMPONENT",
+		"error_class": "ERROR_CLASS",
+		"warp-mode":   "WARP_MODE",
+		"_private":    "PRIVATE",
+		"1st":         "ST",
+		"__":          "FIELD",
+	}
+	for key, want := range tests {
+		if got := journalFieldName(key); got != want {
+			t.Errorf("journalFieldName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package logger
+
+import (
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Writer converting the JSON events of zerolog into logfmt lines, keeping the order of the fields
+type logfmtWriter struct {
+	out io.Writer
+}
+
+func (w *logfmtWriter) Write(p []byte) (int, error) {
+	fields, err := decodeEvent(p)
+	if err != nil {
+		return 0, err
+	}
+
+	var line strings.Builder
+	for i, f := range fields {
+		if i > 0 {
+			line.WriteByte(' ')
+		}
+		line.WriteString(f.Key)
+		line.WriteByte('=')
+		line.WriteString(logfmtValue(f.String()))
+	}
+	line.WriteByte('\n')
+
+	if _, err := io.WriteString(w.out, line.String()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Quote values that would not read back as a single value
+func logfmtValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " =\"\t\r\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -3,16 +3,47 @@ package logger
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
+// Output formats of the log lines
+const (
+	// Human readable, coloured on the console
+	FormatConsole = "console"
+	// One JSON object per line
+	FormatJSON = "json"
+	// One line of key=value pairs per event
+	FormatLogfmt = "logfmt"
+)
+
+var Formats = []string{FormatConsole, FormatJSON, FormatLogfmt}
+
 var initialized bool
 
+var (
+	// Every logger writes through it, so loggers created before Init follow its changes
+	output = &switchWriter{w: zerolog.MultiLevelWriter(os.Stderr)}
+	format = FormatConsole
+	file   *RotatingFile
+	// Whether the logs are written to stdout
+	consoleEnabled = true
+	// Log daemons receiving the raw events, independently of the format
+	backends []io.WriteCloser
+)
+
+func init() {
+	log.Logger = zerolog.New(output).With().Timestamp().Logger()
+}
+
 func Init() error {
 	if initialized {
 		return errors.New("logger is already initialized")
@@ -20,17 +51,165 @@ func Init() error {
 
 	// Configure global settings
 	zerolog.SetGlobalLevel(zerolog.DebugLevel)
-	zerolog.TimeFieldFormat = "15:04:05.000"
+	// Keep the date in the events, the writers shorten it where it is not needed
+	zerolog.TimeFieldFormat = time.RFC3339Nano
+
+	setWriters()
+
+	initialized = true
+	return nil
+}
+
+// Set the minimum level, e.g. "info", and the format of the log lines
+func Configure(levelName string, formatName string) error {
+	level, err := zerolog.ParseLevel(levelName)
+	if err != nil || levelName == "" {
+		return fmt.Errorf("unknown log level '%s'", levelName)
+	}
+	if !slices.Contains(Formats, formatName) {
+		return fmt.Errorf("unknown log format '%s'", formatName)
+	}
+
+	zerolog.SetGlobalLevel(level)
+	format = formatName
+	setWriters()
+	return nil
+}
+
+// Child of the global logger tagging every event with the component, e.g. "warp/serv"
+func Component(name string) zerolog.Logger {
+	return log.Logger.With().Str("component", name).Logger()
+}
+
+// Also write the logs to a file rotated once it is bigger than maxSize bytes or
+// older than maxAge, keeping maxBackups compressed old segments
+func AddFile(path string, maxSize int64, maxAge time.Duration, maxBackups int) error {
+	if !initialized {
+		return errors.New("logger is not initialized")
+	}
+	if file != nil {
+		return errors.New("log file is already added")
+	}
+
+	f, err := OpenRotatingFile(path, maxSize, maxAge, maxBackups)
+	if err != nil {
+		return err
+	}
+	file = f
+	setWriters()
+	return nil
+}
+
+// Also send the logs to journald, tagged with the identifier
+func AddJournald(identifier string) error {
+	w, err := NewJournaldWriter(identifier)
+	if err != nil {
+		return err
+	}
+	backends = append(backends, w)
+	setWriters()
+	return nil
+}
 
-	// Configure custom console writer
+// Also send the logs to a syslog server, e.g. "udp://127.0.0.1:514"
+func AddSyslog(address string, appName string) error {
+	w, err := NewSyslogWriter(address, appName)
+	if err != nil {
+		return err
+	}
+	backends = append(backends, w)
+	setWriters()
+	return nil
+}
+
+// Stop writing the logs to stdout, e.g. when journald already receives them
+func DisableConsole() {
+	consoleEnabled = false
+	setWriters()
+}
+
+// Flush and close the log file and the backends, logging continues on the console only
+func Close() error {
+	closers := backends
+	if file != nil {
+		closers = append(closers, file)
+	}
+	file = nil
+	backends = nil
+	consoleEnabled = true
+	setWriters()
+
+	var errs []error
+	for _, c := range closers {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Point the loggers to the console and the log file in the current format, and to the backends
+func setWriters() {
+	var writers []io.Writer
+	if consoleEnabled {
+		writers = append(writers, newFormatWriter(os.Stdout, "15:04:05.000", true))
+	}
+	if file != nil {
+		writers = append(writers, newFormatWriter(file, "2006-01-02 15:04:05.000", false))
+	}
+	for _, backend := range backends {
+		writers = append(writers, backend)
+	}
+	output.set(zerolog.MultiLevelWriter(writers...))
+}
+
+// Writer converting the JSON events of zerolog into the current format
+func newFormatWriter(out io.Writer, timeFormat string, colored bool) io.Writer {
+	switch format {
+	case FormatJSON:
+		return out
+	case FormatLogfmt:
+		return &logfmtWriter{out: out}
+	default:
+		return newConsoleWriter(out, timeFormat, colored)
+	}
+}
+
+// Writer whose target can be swapped while loggers write to it
+type switchWriter struct {
+	mu sync.RWMutex
+	w  zerolog.LevelWriter
+}
+
+func (s *switchWriter) set(w zerolog.LevelWriter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.w = w
+}
+
+func (s *switchWriter) Write(p []byte) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.w.Write(p)
+}
+
+func (s *switchWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.w.WriteLevel(level, p)
+}
+
+// Human readable writer, coloured for terminals
+func newConsoleWriter(out io.Writer, timeFormat string, colored bool) zerolog.ConsoleWriter {
 	consoleOutput := zerolog.ConsoleWriter{
-		Out:        os.Stdout,
-		TimeFormat: "15:04:05.000",
-		NoColor:    false,
+		Out:        out,
+		TimeFormat: timeFormat,
+		NoColor:    !colored,
 	}
 
 	consoleOutput.FormatLevel = func(i any) string {
 		levelStr := strings.ToUpper(fmt.Sprintf("%s", i))
+		if !colored {
+			return fmt.Sprintf("[%s]", levelStr)
+		}
 
 		switch levelStr {
 		case "DEBUG":
@@ -60,12 +239,5 @@ func Init() error {
 		return fmt.Sprintf("%s", i)
 	}
 
-	// Create a new logger instance
-	newLogger := zerolog.New(consoleOutput).With().Timestamp().Logger()
-
-	// Set the global logger
-	log.Logger = newLogger
-
-	initialized = true
-	return nil
+	return consoleOutput
 }
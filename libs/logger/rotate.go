@@ -0,0 +1,255 @@
+package logger
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Format of the time a segment was rotated at, part of its file name
+const rotateTimeFormat = "20060102-150405.000"
+
+// How long to keep writing to the unrotated file after a failed rotation
+const rotateRetryDelay = time.Minute
+
+// Replaceable to make the rotation fail
+var rename = os.Rename
+
+// Log file that is rotated once it grows too big or too old. Rotated segments
+// are renamed to "<name>-<time><ext>", compressed with gzip and the oldest ones
+// beyond MaxBackups are removed.
+type RotatingFile struct {
+	Path string
+	// Bytes after which the file is rotated, 0 disables rotation by size
+	MaxSize int64
+	// Age after which the file is rotated, 0 disables rotation by age
+	MaxAge time.Duration
+	// Number of rotated segments kept, 0 keeps all of them
+	MaxBackups int
+
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+	started time.Time
+	// No rotation is attempted before, set after a failed one
+	retryAt time.Time
+	// Compression of the rotated segments running in the background
+	compressing sync.WaitGroup
+	cleaning    sync.Mutex
+}
+
+// Open the log file for appending, creating it and its directory when missing
+func OpenRotatingFile(path string, maxSize int64, maxAge time.Duration, maxBackups int) (*RotatingFile, error) {
+	f := &RotatingFile{Path: path, MaxSize: maxSize, MaxAge: maxAge, MaxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0755); err != nil {
+		return fmt.Errorf("could not create log directory:\n %w", err)
+	}
+	file, err := os.OpenFile(f.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("could not open log file:\n %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("could not stat log file:\n %w", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	// The creation time is not portable, so an existing segment is aged from its last write
+	f.started = time.Now()
+	if f.size > 0 {
+		f.started = info.ModTime()
+	}
+	return nil
+}
+
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.shouldRotate(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			if f.file == nil {
+				return 0, err
+			}
+			// Still reopened, keep the line rather than losing it
+			fmt.Fprintf(os.Stderr, "Could not rotate log file: %v\n", err)
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *RotatingFile) shouldRotate(next int64) bool {
+	if f.size == 0 || time.Now().Before(f.retryAt) {
+		return false
+	}
+	if f.MaxSize > 0 && f.size+next > f.MaxSize {
+		return true
+	}
+	return f.MaxAge > 0 && time.Since(f.started) > f.MaxAge
+}
+
+// Rotate the file right away
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rotate()
+}
+
+func (f *RotatingFile) rotate() error {
+	err := f.file.Close()
+	f.file = nil
+	if err != nil {
+		return f.recover(fmt.Errorf("could not close log file:\n %w", err))
+	}
+
+	ext := filepath.Ext(f.Path)
+	rotated := strings.TrimSuffix(f.Path, ext) + "-" + time.Now().Format(rotateTimeFormat) + ext
+	if err := rename(f.Path, rotated); err != nil {
+		return f.recover(fmt.Errorf("could not rotate log file:\n %w", err))
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.retryAt = time.Time{}
+
+	f.compressing.Add(1)
+	go func() {
+		defer f.compressing.Done()
+		f.cleanUp()
+	}()
+	return nil
+}
+
+// Reopen the unrotated file after a failed rotation, which is retried after rotateRetryDelay
+func (f *RotatingFile) recover(cause error) error {
+	f.retryAt = time.Now().Add(rotateRetryDelay)
+	if err := f.open(); err != nil {
+		return errors.Join(cause, err)
+	}
+	return cause
+}
+
+// Compress the rotated segments, including ones left over by an earlier run,
+// and remove the oldest segments beyond MaxBackups
+func (f *RotatingFile) cleanUp() {
+	f.cleaning.Lock()
+	defer f.cleaning.Unlock()
+
+	segments, err := f.segments()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not list rotated log files: %v\n", err)
+		return
+	}
+
+	for i, segment := range segments {
+		if strings.HasSuffix(segment, ".gz") {
+			continue
+		}
+		if err := compressFile(segment); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not compress rotated log file: %v\n", err)
+			continue
+		}
+		segments[i] = segment + ".gz"
+	}
+
+	if f.MaxBackups <= 0 || len(segments) <= f.MaxBackups {
+		return
+	}
+	for _, segment := range segments[:len(segments)-f.MaxBackups] {
+		if err := os.Remove(segment); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not remove rotated log file: %v\n", err)
+		}
+	}
+}
+
+// Rotated segments of the file, oldest first
+func (f *RotatingFile) segments() ([]string, error) {
+	ext := filepath.Ext(f.Path)
+	pattern := strings.TrimSuffix(f.Path, ext) + "-*" + ext
+	plain, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	compressed, err := filepath.Glob(pattern + ".gz")
+	if err != nil {
+		return nil, err
+	}
+
+	// The time in the name sorts chronologically
+	segments := append(plain, compressed...)
+	slices.SortFunc(segments, func(a, b string) int {
+		return strings.Compare(strings.TrimSuffix(a, ".gz"), strings.TrimSuffix(b, ".gz"))
+	})
+	return segments, nil
+}
+
+// Replace a file with its gzip compressed copy "<path>.gz"
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := path + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dst)
+	zw.Name = filepath.Base(path)
+	_, err = io.Copy(zw, src)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path+".gz")
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	src.Close()
+	return os.Remove(path)
+}
+
+// Close the file after the running compressions finished
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.compressing.Wait()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
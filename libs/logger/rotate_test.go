@@ -0,0 +1,72 @@
+package logger
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotatingFileRotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ezforce.log")
+	f, err := OpenRotatingFile(path, 10, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{"first\n", "second\n", "third\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "third\n" {
+		t.Errorf("log file = %q, want the last line only", data)
+	}
+	segments, err := f.segments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 1 || filepath.Ext(segments[0]) != ".gz" {
+		t.Errorf("segments = %v, want one compressed segment", segments)
+	}
+}
+
+func TestRotatingFileKeepsWritingWhenRenameFails(t *testing.T) {
+	rename = func(string, string) error { return errors.New("file in use") }
+	t.Cleanup(func() { rename = os.Rename })
+
+	path := filepath.Join(t.TempDir(), "ezforce.log")
+	f, err := OpenRotatingFile(path, 10, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write([]byte("first line\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Rotate(); err == nil {
+		t.Fatal("Rotate() succeeded although the rename failed")
+	}
+	for _, line := range []string{"second line\n", "third line\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() after the failed rotation error = %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "first line\nsecond line\nthird line\n" {
+		t.Errorf("log file = %q, want every line kept", data)
+	}
+}
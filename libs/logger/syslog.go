@@ -0,0 +1,160 @@
+package logger
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Facility of the messages, "system daemons"
+const syslogFacility = 3
+
+// RFC 5424 timestamp, limited to the microseconds the RFC allows
+const syslogTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// Structured data ID of the event fields, using the example enterprise number of RFC 5424
+const syslogSDID = "ezforce@32473"
+
+// Writer sending the JSON events of zerolog as RFC 5424 syslog messages.
+// The event fields are sent as structured data, e.g. [ezforce@32473 component="warp"].
+type SyslogWriter struct {
+	Network  string
+	Address  string
+	AppName  string
+	Hostname string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// Connect to a syslog server given as "udp://host:port", "tcp://host:port" or "unixgram:///dev/log"
+func NewSyslogWriter(address string, appName string) (*SyslogWriter, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid syslog address '%s':\n %w", address, err)
+	}
+	w := &SyslogWriter{Network: u.Scheme, AppName: appName}
+	switch u.Scheme {
+	case "udp", "tcp":
+		w.Address = u.Host
+	case "unix", "unixgram":
+		w.Address = u.Path
+	default:
+		return nil, fmt.Errorf("unsupported syslog network '%s'", u.Scheme)
+	}
+
+	w.Hostname, err = os.Hostname()
+	if err != nil {
+		w.Hostname = "-"
+	}
+
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *SyslogWriter) connect() error {
+	conn, err := net.Dial(w.Network, w.Address)
+	if err != nil {
+		return fmt.Errorf("could not connect to syslog:\n %w", err)
+	}
+	w.conn = conn
+	return nil
+}
+
+func (w *SyslogWriter) Write(p []byte) (int, error) {
+	fields, err := decodeEvent(p)
+	if err != nil {
+		return 0, err
+	}
+	message := w.format(fields, time.Now())
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// Reconnect once, e.g. after the server restarted
+	if w.conn == nil || w.send(message) != nil {
+		if w.conn != nil {
+			w.conn.Close()
+			w.conn = nil
+		}
+		if err := w.connect(); err != nil {
+			return 0, err
+		}
+		if err := w.send(message); err != nil {
+			return 0, fmt.Errorf("could not write to syslog:\n %w", err)
+		}
+	}
+	return len(p), nil
+}
+
+// Send one message, framed by octet counting on stream connections
+func (w *SyslogWriter) send(message string) error {
+	if w.Network == "tcp" || w.Network == "unix" {
+		message = strconv.Itoa(len(message)) + " " + message
+	}
+	_, err := w.conn.Write([]byte(message))
+	return err
+}
+
+// RFC 5424 message: <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD] MSG
+func (w *SyslogWriter) format(fields []field, now time.Time) string {
+	var message string
+	var sd strings.Builder
+	for _, f := range fields {
+		switch f.Key {
+		case zerolog.LevelFieldName, zerolog.TimestampFieldName:
+		case zerolog.MessageFieldName:
+			message = f.String()
+		default:
+			fmt.Fprintf(&sd, " %s=\"%s\"", syslogParamName(f.Key), syslogParamValue(f.String()))
+		}
+	}
+
+	structured := "-"
+	if sd.Len() > 0 {
+		structured = "[" + syslogSDID + sd.String() + "]"
+	}
+
+	pri := syslogFacility*8 + severity(eventLevel(fields))
+	return fmt.Sprintf("<%d>1 %s %s %s %d - %s %s",
+		pri, now.Format(syslogTimeFormat), w.Hostname, w.AppName, os.Getpid(), structured, message)
+}
+
+func (w *SyslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// Parameter names are printable ASCII without '=', ' ', ']' and '"', at most 32 characters
+func syslogParamName(key string) string {
+	name := []byte(key)
+	for i, c := range name {
+		if c <= ' ' || c > '~' || c == '=' || c == ']' || c == '"' {
+			name[i] = '_'
+		}
+	}
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	return string(name)
+}
+
+// Parameter values escape '"', '\' and ']'
+func syslogParamValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
@@ -0,0 +1,114 @@
+package logger
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const syslogEvent = `{"level":"warn","component":"warp","detail":"say \"hi\" [x] \\ y","time":"2026-01-02T03:04:05Z","message":"Warp disconnected"}`
+
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD] MSG
+var syslogLine = regexp.MustCompile(`^<(\d+)>1 (\S+) (\S+) (\S+) (\d+) - (\[.*\]|-) (.*)$`)
+
+func checkSyslogMessage(t *testing.T, w *SyslogWriter, message string) {
+	t.Helper()
+	m := syslogLine.FindStringSubmatch(message)
+	if m == nil {
+		t.Fatalf("not an RFC 5424 message: %q", message)
+	}
+
+	// Facility 3 (daemon) and severity 4 (warning)
+	if m[1] != "28" {
+		t.Errorf("PRI = %s, want 28", m[1])
+	}
+	if _, err := time.Parse(time.RFC3339, m[2]); err != nil || !regexp.MustCompile(`\.\d{6}(Z|[+-]\d\d:\d\d)$`).MatchString(m[2]) {
+		t.Errorf("TIMESTAMP = %s, want RFC 3339 with microseconds", m[2])
+	}
+	if m[3] != w.Hostname || m[4] != "ezforce" || m[5] != strconv.Itoa(os.Getpid()) {
+		t.Errorf("header = %s %s %s, want %s ezforce %d", m[3], m[4], m[5], w.Hostname, os.Getpid())
+	}
+	wantSD := `[ezforce@32473 component="warp" detail="say \"hi\" [x\] \\ y"]`
+	if m[6] != wantSD {
+		t.Errorf("SD = %s, want %s", m[6], wantSD)
+	}
+	if m[7] != "Warp disconnected" {
+		t.Errorf("MSG = %q, want %q", m[7], "Warp disconnected")
+	}
+}
+
+func TestSyslogWriterUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	w, err := NewSyslogWriter("udp://"+conn.LocalAddr().String(), "ezforce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if _, err := w.Write([]byte(syslogEvent)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSyslogMessage(t, w, string(buf[:n]))
+}
+
+func TestSyslogWriterTCPFraming(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	w, err := NewSyslogWriter("tcp://"+listener.Addr().String(), "ezforce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	for i := 0; i < 2; i++ {
+		if _, err := w.Write([]byte(syslogEvent)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	// Octet counting: "<length> <message>" with nothing separating the frames
+	r := bufio.NewReader(conn)
+	for i := 0; i < 2; i++ {
+		length, err := r.ReadString(' ')
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+		if err != nil {
+			t.Fatalf("frame %d without octet count: %q", i, length)
+		}
+		message := make([]byte, n)
+		if _, err := io.ReadFull(r, message); err != nil {
+			t.Fatal(err)
+		}
+		checkSyslogMessage(t, w, string(message))
+	}
+}
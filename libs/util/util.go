@@ -6,6 +6,7 @@ import (
 	"os"
 
 	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
 )
 
 // Stop the execution of the program until the user presses Enter.
@@ -23,3 +24,9 @@ func WaitForInput() error {
 
 	return nil
 }
+
+// Check if stdin is a terminal a user could answer prompts on.
+func IsInteractive() bool {
+	fd := os.Stdin.Fd()
+	return isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+}
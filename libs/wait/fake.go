@@ -0,0 +1,39 @@
+package wait
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock advancing by each requested delay instead of sleeping, to run waits in tests
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+	// Delays requested so far, in order
+	Waits []time.Duration
+	// Never fire the timers when set, the wait only ends with its context
+	Stuck bool
+}
+
+// Fake clock starting at the Unix epoch
+func NewFakeClock() *FakeClock {
+	return &FakeClock{now: time.Unix(0, 0)}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Waits = append(c.Waits, d)
+	ch := make(chan time.Time, 1)
+	if !c.Stuck {
+		c.now = c.now.Add(d)
+		ch <- c.now
+	}
+	return ch
+}
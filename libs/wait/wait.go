@@ -0,0 +1,130 @@
+package wait
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// Returned when the condition was not met within the policy's attempts
+var ErrExhausted = errors.New("condition not met")
+
+// Returned when the condition was not met before the policy's timeout
+var ErrTimeout = errors.New("timed out waiting for condition")
+
+// Source of time, replaceable to control the waits
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Clock backed by the time package
+var RealClock Clock = realClock{}
+
+// Condition being waited for, reporting whether it is met
+type Predicate func(ctx context.Context) (bool, error)
+
+// How often and for how long to check a condition
+type Policy struct {
+	// Total number of checks including the first, immediate one. 0 means unlimited.
+	MaxAttempts int
+	// Delay before the second check
+	InitialDelay time.Duration
+	// Upper bound of the delay between two checks, 0 means unbounded
+	MaxDelay time.Duration
+	// Growth factor of the delay after each check, values below 1 keep it constant
+	Multiplier float64
+	// Random spread of each delay as a fraction of it, e.g. 0.1 for ±10%
+	Jitter float64
+	// Overall time limit of the wait, 0 means none
+	Timeout time.Duration
+	// Called before sleeping after an unmet check
+	OnProgress func(attempt int, maxAttempts int, next time.Duration)
+	// Defaults to RealClock
+	Clock Clock
+	// Source of the jitter in [0, 1), defaults to math/rand
+	Rand func() float64
+}
+
+// Delay before the check following the given attempt, without jitter
+func (p Policy) delay(attempt int) time.Duration {
+	delay := float64(p.InitialDelay)
+	if p.Multiplier > 1 {
+		for i := 1; i < attempt; i++ {
+			delay *= p.Multiplier
+			if p.MaxDelay > 0 && delay >= float64(p.MaxDelay) {
+				break
+			}
+		}
+	}
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	return time.Duration(delay)
+}
+
+func (p Policy) jitter(delay time.Duration) time.Duration {
+	if p.Jitter <= 0 {
+		return delay
+	}
+	random := p.Rand
+	if random == nil {
+		random = rand.Float64
+	}
+	spread := float64(delay) * p.Jitter * (2*random() - 1)
+	return max(0, delay+time.Duration(spread))
+}
+
+// Check the predicate right away and then after each backoff delay until it
+// is met, returns an error, the attempts run out, the timeout passes or ctx is done
+func WaitFor(ctx context.Context, predicate Predicate, policy Policy) error {
+	clock := policy.Clock
+	if clock == nil {
+		clock = RealClock
+	}
+
+	var deadline time.Time
+	if policy.Timeout > 0 {
+		deadline = clock.Now().Add(policy.Timeout)
+	}
+
+	for attempt := 1; ; attempt++ {
+		met, err := predicate(ctx)
+		if err != nil {
+			return err
+		}
+		if met {
+			return nil
+		}
+
+		if policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts {
+			return fmt.Errorf("%w after %d attempts", ErrExhausted, attempt)
+		}
+
+		next := policy.jitter(policy.delay(attempt))
+		if !deadline.IsZero() {
+			remaining := deadline.Sub(clock.Now())
+			if remaining <= 0 {
+				return fmt.Errorf("%w after %v (%d attempts)", ErrTimeout, policy.Timeout, attempt)
+			}
+			next = min(next, remaining)
+		}
+
+		if policy.OnProgress != nil {
+			policy.OnProgress(attempt, policy.MaxAttempts, next)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-clock.After(next):
+		}
+	}
+}
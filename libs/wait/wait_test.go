@@ -0,0 +1,218 @@
+package wait
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Predicate met on the given attempt, never when 0
+func metOn(attempt int, calls *int) Predicate {
+	return func(ctx context.Context) (bool, error) {
+		*calls++
+		return attempt > 0 && *calls >= attempt, nil
+	}
+}
+
+func TestWaitForMetImmediately(t *testing.T) {
+	clock := NewFakeClock()
+	calls := 0
+	err := WaitFor(context.Background(), metOn(1, &calls), Policy{MaxAttempts: 3, InitialDelay: time.Second, Clock: clock})
+	if err != nil {
+		t.Fatalf("WaitFor() error = %v", err)
+	}
+	if calls != 1 || len(clock.Waits) != 0 {
+		t.Errorf("calls = %d, waits = %v, want a single check without waiting", calls, clock.Waits)
+	}
+}
+
+func TestWaitForAttempts(t *testing.T) {
+	clock := NewFakeClock()
+	calls := 0
+	var progress []int
+	policy := Policy{
+		MaxAttempts:  5,
+		InitialDelay: 100 * time.Millisecond,
+		Clock:        clock,
+		OnProgress: func(attempt int, maxAttempts int, next time.Duration) {
+			if maxAttempts != 5 {
+				t.Errorf("OnProgress maxAttempts = %d, want 5", maxAttempts)
+			}
+			progress = append(progress, attempt)
+		},
+	}
+
+	err := WaitFor(context.Background(), metOn(0, &calls), policy)
+	if !errors.Is(err, ErrExhausted) {
+		t.Fatalf("WaitFor() error = %v, want %v", err, ErrExhausted)
+	}
+	if calls != 5 {
+		t.Errorf("predicate called %d times, want 5", calls)
+	}
+	if len(clock.Waits) != 4 {
+		t.Errorf("waited %d times, want 4", len(clock.Waits))
+	}
+	if want := []int{1, 2, 3, 4}; !slices.Equal(progress, want) {
+		t.Errorf("OnProgress attempts = %v, want %v", progress, want)
+	}
+
+	calls = 0
+	if err := WaitFor(context.Background(), metOn(3, &calls), policy); err != nil {
+		t.Fatalf("WaitFor() error = %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("predicate called %d times, want 3", calls)
+	}
+}
+
+func TestWaitForBackoff(t *testing.T) {
+	clock := NewFakeClock()
+	calls := 0
+	err := WaitFor(context.Background(), metOn(0, &calls), Policy{
+		MaxAttempts:  8,
+		InitialDelay: 100 * time.Millisecond,
+		MaxDelay:     time.Second,
+		Multiplier:   2,
+		Clock:        clock,
+	})
+	if !errors.Is(err, ErrExhausted) {
+		t.Fatalf("WaitFor() error = %v, want %v", err, ErrExhausted)
+	}
+
+	want := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+		time.Second,
+	}
+	if !slices.Equal(clock.Waits, want) {
+		t.Errorf("waits = %v, want %v", clock.Waits, want)
+	}
+}
+
+func TestWaitForConstantDelay(t *testing.T) {
+	clock := NewFakeClock()
+	calls := 0
+	WaitFor(context.Background(), metOn(0, &calls), Policy{
+		MaxAttempts:  4,
+		InitialDelay: 100 * time.Millisecond,
+		Multiplier:   0.5,
+		Clock:        clock,
+	})
+
+	for _, wait := range clock.Waits {
+		if wait != 100*time.Millisecond {
+			t.Errorf("waits = %v, want a constant 100ms", clock.Waits)
+			break
+		}
+	}
+}
+
+func TestWaitForJitter(t *testing.T) {
+	tests := []struct {
+		random float64
+		want   time.Duration
+	}{
+		{0, 900 * time.Millisecond},
+		{0.25, 950 * time.Millisecond},
+		{0.5, time.Second},
+		{0.75, 1050 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		clock := NewFakeClock()
+		calls := 0
+		WaitFor(context.Background(), metOn(2, &calls), Policy{
+			InitialDelay: time.Second,
+			Jitter:       0.1,
+			Clock:        clock,
+			Rand:         func() float64 { return tt.random },
+		})
+		if len(clock.Waits) != 1 || clock.Waits[0] != tt.want {
+			t.Errorf("rand %v: waits = %v, want [%v]", tt.random, clock.Waits, tt.want)
+		}
+	}
+
+	// Whatever the random source gives, the delay stays within the spread
+	clock := NewFakeClock()
+	calls := 0
+	WaitFor(context.Background(), metOn(0, &calls), Policy{
+		MaxAttempts:  200,
+		InitialDelay: time.Second,
+		Jitter:       0.1,
+		Clock:        clock,
+	})
+	for _, wait := range clock.Waits {
+		if wait < 900*time.Millisecond || wait > 1100*time.Millisecond {
+			t.Fatalf("jittered delay %v outside [900ms, 1.1s]", wait)
+		}
+	}
+}
+
+func TestWaitForTimeout(t *testing.T) {
+	clock := NewFakeClock()
+	calls := 0
+	err := WaitFor(context.Background(), metOn(0, &calls), Policy{
+		InitialDelay: 100 * time.Millisecond,
+		Multiplier:   2,
+		Timeout:      250 * time.Millisecond,
+		Clock:        clock,
+	})
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("WaitFor() error = %v, want %v", err, ErrTimeout)
+	}
+
+	// The second delay is clamped to what is left before the deadline
+	want := []time.Duration{100 * time.Millisecond, 150 * time.Millisecond}
+	if !slices.Equal(clock.Waits, want) {
+		t.Errorf("waits = %v, want %v", clock.Waits, want)
+	}
+	if calls != 3 {
+		t.Errorf("predicate called %d times, want 3", calls)
+	}
+}
+
+func TestWaitForContextCanceled(t *testing.T) {
+	clock := NewFakeClock()
+	clock.Stuck = true
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	predicate := func(ctx context.Context) (bool, error) {
+		calls++
+		cancel()
+		return false, nil
+	}
+
+	err := WaitFor(ctx, predicate, Policy{InitialDelay: time.Hour, Clock: clock})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("WaitFor() error = %v, want %v", err, context.Canceled)
+	}
+	if calls != 1 {
+		t.Errorf("predicate called %d times, want 1", calls)
+	}
+}
+
+func TestWaitForPredicateError(t *testing.T) {
+	clock := NewFakeClock()
+	failure := errors.New("check failed")
+	calls := 0
+	predicate := func(ctx context.Context) (bool, error) {
+		calls++
+		if calls == 2 {
+			return false, failure
+		}
+		return false, nil
+	}
+
+	err := WaitFor(context.Background(), predicate, Policy{MaxAttempts: 5, InitialDelay: time.Second, Clock: clock})
+	if !errors.Is(err, failure) {
+		t.Fatalf("WaitFor() error = %v, want %v", err, failure)
+	}
+	if calls != 2 || len(clock.Waits) != 1 {
+		t.Errorf("calls = %d, waits = %v, want to stop at the error", calls, clock.Waits)
+	}
+}
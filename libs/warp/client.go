@@ -0,0 +1,189 @@
+package warp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Backend used to talk to the Warp client
+type WarpClient interface {
+	// Current Warp connection status
+	Status() (*ConnectionStatus, error)
+	// Connect Warp to the Cloudflare service
+	Connect() error
+	// Disconnect Warp from the Cloudflare service
+	Disconnect() error
+	// Currently configured Warp mode
+	Mode() (Mode, error)
+	// Switch Warp to the given mode
+	SetMode(mode Mode) error
+	// Current Cloudflare for Families DNS filtering level
+	FamiliesMode() (FamiliesMode, error)
+	// Switch the Cloudflare for Families DNS filtering level
+	SetFamiliesMode(mode FamiliesMode) error
+	// Merged Warp settings as key/value pairs
+	Settings() (map[string]string, error)
+}
+
+// Client used by the package level functions
+var Client WarpClient = &CliClient{Path: "warp-cli"}
+
+// WarpClient backend running the 'warp-cli' executable
+type CliClient struct {
+	Path string
+
+	// Set once the client rejected the '--json' flag
+	noJSON bool
+}
+
+func (c *CliClient) Status() (*ConnectionStatus, error) {
+	var out string
+	var err error
+	if !c.noJSON {
+		out, err = c.run("--json", "status")
+		if flagRejected(err, "--json") {
+			// Older clients do not know the flag, any other error is not about it
+			c.noJSON = true
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if c.noJSON {
+		out, err = c.run("status")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	status, err := ParseStatus(out)
+	if err != nil {
+		return nil, err
+	}
+
+	// Status output does not always include the mode
+	if status.Mode == "" {
+		if mode, err := c.Mode(); err == nil {
+			status.Mode = string(mode)
+		}
+	}
+	return status, nil
+}
+
+func (c *CliClient) Connect() error {
+	_, err := c.run("connect")
+	return err
+}
+
+func (c *CliClient) Disconnect() error {
+	_, err := c.run("disconnect")
+	return err
+}
+
+func (c *CliClient) Mode() (Mode, error) {
+	settings, err := c.Settings()
+	if err != nil {
+		return "", err
+	}
+	return ParseMode(settings["Mode"])
+}
+
+func (c *CliClient) SetMode(mode Mode) error {
+	_, err := c.run("mode", string(mode))
+	return err
+}
+
+func (c *CliClient) FamiliesMode() (FamiliesMode, error) {
+	settings, err := c.Settings()
+	if err != nil {
+		return "", err
+	}
+	return familiesModeFromSettings(settings)
+}
+
+func (c *CliClient) SetFamiliesMode(mode FamiliesMode) error {
+	_, err := c.run("dns", "families", string(mode))
+	return err
+}
+
+func (c *CliClient) Settings() (map[string]string, error) {
+	out, err := c.run("settings")
+	if err != nil {
+		return nil, err
+	}
+	return parseSettings(out), nil
+}
+
+func (c *CliClient) run(args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command(c.Path, args...)
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = strings.TrimSpace(string(out))
+		}
+		return "", &cliError{Command: c.Path + " " + strings.Join(args, " "), Output: msg, Err: err}
+	}
+	return string(out), nil
+}
+
+// Failed 'warp-cli' invocation
+type cliError struct {
+	Command string
+	// What the client printed about the failure
+	Output string
+	Err    error
+}
+
+func (e *cliError) Error() string {
+	return fmt.Sprintf("'%s' failed: %s:\n %v", e.Command, e.Output, e.Err)
+}
+
+func (e *cliError) Unwrap() error {
+	return e.Err
+}
+
+// Check if the client failed because it does not know the flag, e.g.
+// "error: unexpected argument '--json' found"
+func flagRejected(err error, flag string) bool {
+	var cliErr *cliError
+	if !errors.As(err, &cliErr) || !strings.Contains(cliErr.Output, flag) {
+		return false
+	}
+	output := strings.ToLower(cliErr.Output)
+	for _, marker := range []string{"unexpected argument", "wasn't expected", "unrecognized", "unknown"} {
+		if strings.Contains(output, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse the 'warp-cli settings' output into key/value pairs.
+// Lines look like "(local policy)\tMode: Warp", the origin prefix is dropped.
+func parseSettings(out string) map[string]string {
+	settings := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "(") {
+			if end := strings.Index(line, ")"); end >= 0 {
+				line = strings.TrimSpace(line[end+1:])
+			}
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		settings[key] = strings.TrimSpace(value)
+	}
+	return settings
+}
@@ -0,0 +1,90 @@
+package warp
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestFlagRejected(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		reject bool
+	}{
+		{"nil", nil, false},
+		{"other error", errors.New("unexpected argument '--json'"), false},
+		{"clap unexpected", &cliError{Output: "error: unexpected argument '--json' found"}, true},
+		{"clap old", &cliError{Output: "error: Found argument '--json' which wasn't expected"}, true},
+		{"daemon down", &cliError{Output: "Error communicating with daemon"}, false},
+		{"unknown other flag", &cliError{Output: "error: unexpected argument '--foo' found"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := flagRejected(tt.err, "--json"); got != tt.reject {
+				t.Errorf("flagRejected() = %v, want %v", got, tt.reject)
+			}
+		})
+	}
+}
+
+// Fake warp-cli printing the given script's output
+func fakeWarpCli(t *testing.T, script string) *CliClient {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake warp-cli is a shell script")
+	}
+	path := filepath.Join(t.TempDir(), "warp-cli")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return &CliClient{Path: path}
+}
+
+func TestStatusKeepsJSONAfterTransientError(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "up")
+	c := fakeWarpCli(t, `
+if [ ! -e `+marker+` ]; then echo "Error communicating with daemon" >&2; exit 1; fi
+if [ "$1" = "--json" ]; then echo '{"status":"Connected","mode":"warp"}'; exit 0; fi
+echo "Status update: Disconnected"
+`)
+
+	if _, err := c.Status(); err == nil {
+		t.Fatal("Status() succeeded while the daemon is down")
+	}
+	if c.noJSON {
+		t.Fatal("JSON status turned off by an error unrelated to the flag")
+	}
+
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	status, err := c.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if !status.IsConnected() {
+		t.Errorf("Status() = %v, want the JSON output", status)
+	}
+}
+
+func TestStatusFallsBackWhenJSONRejected(t *testing.T) {
+	c := fakeWarpCli(t, `
+if [ "$1" = "--json" ]; then echo "error: unexpected argument '--json' found" >&2; exit 2; fi
+if [ "$1" = "settings" ]; then echo "Mode: Warp"; exit 0; fi
+echo "Status update: Connected"
+`)
+
+	status, err := c.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if !c.noJSON {
+		t.Error("JSON status still used after the client rejected the flag")
+	}
+	if !status.IsConnected() {
+		t.Errorf("Status() = %v, want Connected", status)
+	}
+}
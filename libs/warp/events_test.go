@@ -0,0 +1,117 @@
+package warp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ezydark/ezforce/app/config"
+	"github.com/ezydark/ezforce/libs/events"
+	"github.com/ezydark/ezforce/libs/events/eventstest"
+)
+
+// Require the given mode and families level through the environment layer
+func requirePolicy(t *testing.T, mode Mode, families FamiliesMode) {
+	t.Helper()
+	t.Setenv("EZFORCE_APP_INSTALLPATH", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("EZFORCE_WARP_REQUIREDMODE", string(mode))
+	t.Setenv("EZFORCE_WARP_FAMILIESMODE", string(families))
+	if err := config.Load(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnsureIsConnectedEvents(t *testing.T) {
+	tests := []eventstest.Case[*FakeClient]{
+		{Name: "already correct", Setup: func(f *FakeClient) {}, Observed: "Connected", Action: events.ActionNone, ErrClass: events.ErrorNone},
+		{Name: "corrected", Setup: func(f *FakeClient) { f.Connected, f.ConnectAfter = false, 2 }, Observed: "Disconnected", Action: events.ActionConnect, ErrClass: events.ErrorNone},
+		{Name: "query failed", Setup: func(f *FakeClient) { f.Err = eventstest.ErrFake }, Observed: "", Action: events.ActionNone, ErrClass: events.ErrorQuery},
+		{Name: "action failed", Setup: func(f *FakeClient) { f.Connected, f.Errs = false, map[string]error{"Connect": eventstest.ErrFake} }, Observed: "Disconnected", Action: events.ActionConnect, ErrClass: events.ErrorAction},
+		{Name: "timeout", Setup: func(f *FakeClient) { f.Connected, f.Locked = false, true }, Observed: "Disconnected", Action: events.ActionConnect, ErrClass: events.ErrorTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			fake, _ := useFakeClient(t)
+			fake.Connected = true
+			tt.Setup(fake)
+			recorder := eventstest.Record(t)
+
+			err := EnsureIsConnected(context.Background())
+			eventstest.Check(t, recorder, err, "warp", events.StepConnected, string(StateConnected), tt)
+		})
+	}
+}
+
+func TestEnsureModeEvents(t *testing.T) {
+	tests := []eventstest.Case[*FakeClient]{
+		{Name: "already correct", Setup: func(f *FakeClient) {}, Observed: "warp", Action: events.ActionNone, ErrClass: events.ErrorNone},
+		{Name: "corrected", Setup: func(f *FakeClient) { f.CurrentMode = ModeDoH }, Observed: "doh", Action: events.ActionSetMode, ErrClass: events.ErrorNone},
+		{Name: "query failed", Setup: func(f *FakeClient) { f.Err = eventstest.ErrFake }, Observed: "", Action: events.ActionNone, ErrClass: events.ErrorQuery},
+		{Name: "action failed", Setup: func(f *FakeClient) { f.CurrentMode, f.Errs = ModeDoH, map[string]error{"SetMode": eventstest.ErrFake} }, Observed: "doh", Action: events.ActionSetMode, ErrClass: events.ErrorAction},
+		{Name: "verify failed", Setup: func(f *FakeClient) { f.CurrentMode, f.Locked = ModeDoH, true }, Observed: "doh", Action: events.ActionSetMode, ErrClass: events.ErrorVerify},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			requirePolicy(t, ModeWarp, FamiliesOff)
+			fake, _ := useFakeClient(t)
+			tt.Setup(fake)
+			recorder := eventstest.Record(t)
+
+			err := EnsureMode()
+			eventstest.Check(t, recorder, err, "warp", events.StepMode, string(ModeWarp), tt)
+		})
+	}
+}
+
+// The required mode is passed to warp-cli by its argument name, whatever its spelling in the config
+func TestEnsureModeParsesRequiredMode(t *testing.T) {
+	requirePolicy(t, "WarpWithDnsOverHttps", FamiliesOff)
+	fake, _ := useFakeClient(t)
+	fake.CurrentMode = ModeDoH
+	recorder := eventstest.Record(t)
+
+	tt := eventstest.Case[*FakeClient]{Observed: "doh", Action: events.ActionSetMode, ErrClass: events.ErrorNone}
+	err := EnsureMode()
+	eventstest.Check(t, recorder, err, "warp", events.StepMode, string(ModeWarpDoH), tt)
+	if fake.CurrentMode != ModeWarpDoH {
+		t.Errorf("mode = %q, want %q", fake.CurrentMode, ModeWarpDoH)
+	}
+}
+
+func TestEnsureModeInvalidRequiredMode(t *testing.T) {
+	requirePolicy(t, "vpn", FamiliesOff)
+	fake, _ := useFakeClient(t)
+	recorder := eventstest.Record(t)
+
+	err := EnsureMode()
+	if err == nil || count(fake.Calls, "SetMode") != 0 {
+		t.Fatalf("EnsureMode() error = %v, calls %v, want the config refused without switching", err, fake.Calls)
+	}
+	if emitted := recorder.Events(); len(emitted) != 1 || emitted[0].Desired != "vpn" {
+		t.Errorf("emitted %+v, want one event desiring the configured mode", emitted)
+	}
+}
+
+func TestEnsureFamiliesModeEvents(t *testing.T) {
+	tests := []eventstest.Case[*FakeClient]{
+		{Name: "already correct", Setup: func(f *FakeClient) {}, Observed: "full", Action: events.ActionNone, ErrClass: events.ErrorNone},
+		{Name: "corrected", Setup: func(f *FakeClient) { f.Families = FamiliesOff }, Observed: "off", Action: events.ActionSetFamilies, ErrClass: events.ErrorNone},
+		{Name: "query failed", Setup: func(f *FakeClient) { f.Err = eventstest.ErrFake }, Observed: "", Action: events.ActionNone, ErrClass: events.ErrorQuery},
+		{Name: "action failed", Setup: func(f *FakeClient) {
+			f.Families, f.Errs = FamiliesOff, map[string]error{"SetFamiliesMode": eventstest.ErrFake}
+		}, Observed: "off", Action: events.ActionSetFamilies, ErrClass: events.ErrorAction},
+		{Name: "verify failed", Setup: func(f *FakeClient) { f.Families, f.Locked = FamiliesOff, true }, Observed: "off", Action: events.ActionSetFamilies, ErrClass: events.ErrorVerify},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			requirePolicy(t, ModeWarp, FamiliesMalware)
+			fake, _ := useFakeClient(t)
+			fake.Families = FamiliesFull
+			tt.Setup(fake)
+			recorder := eventstest.Record(t)
+
+			err := EnsureFamiliesMode()
+			eventstest.Check(t, recorder, err, "warp", events.StepFamilies, string(FamiliesMalware), tt)
+		})
+	}
+}
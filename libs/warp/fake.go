@@ -0,0 +1,166 @@
+package warp
+
+import (
+	"maps"
+	"sync"
+)
+
+// In-memory WarpClient used in place of a real Warp install
+type FakeClient struct {
+	mu sync.Mutex
+
+	Connected bool
+	// Number of Status calls after Connect before the fake reports connected
+	ConnectAfter int
+	CurrentMode  Mode
+	Families     FamiliesMode
+	// Extra settings returned next to the mode
+	ExtraSettings map[string]string
+	// Error returned by every call when set
+	Err error
+	// Errors returned by single methods, e.g. "SetMode", taking precedence over Err
+	Errs map[string]error
+	// Accept changes without applying them, like a client locked by a management policy
+	Locked bool
+	// Names of the methods called, in order
+	Calls []string
+
+	pendingConnect int
+	connecting     bool
+}
+
+func NewFakeClient() *FakeClient {
+	return &FakeClient{
+		CurrentMode:   ModeWarp,
+		Families:      FamiliesOff,
+		ExtraSettings: make(map[string]string),
+	}
+}
+
+func (f *FakeClient) call(name string) error {
+	f.Calls = append(f.Calls, name)
+	if err := f.Errs[name]; err != nil {
+		return err
+	}
+	return f.Err
+}
+
+func (f *FakeClient) Status() (*ConnectionStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("Status"); err != nil {
+		return nil, err
+	}
+
+	if f.connecting {
+		if f.pendingConnect > 0 {
+			f.pendingConnect--
+			return &ConnectionStatus{
+				State:    StateConnecting,
+				RawState: "Connecting",
+				Reason:   "Establishing connection",
+				Mode:     string(f.CurrentMode),
+			}, nil
+		}
+		f.connecting = false
+		f.Connected = true
+	}
+
+	if f.Connected {
+		return &ConnectionStatus{
+			State:    StateConnected,
+			RawState: "Connected",
+			Network:  "healthy",
+			Mode:     string(f.CurrentMode),
+		}, nil
+	}
+	return &ConnectionStatus{
+		State:    StateDisconnected,
+		RawState: "Disconnected",
+		Reason:   "Manual Disconnection",
+		Mode:     string(f.CurrentMode),
+	}, nil
+}
+
+func (f *FakeClient) Connect() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("Connect"); err != nil {
+		return err
+	}
+	if !f.Connected && !f.Locked {
+		f.connecting = true
+		f.pendingConnect = f.ConnectAfter
+	}
+	return nil
+}
+
+func (f *FakeClient) Disconnect() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("Disconnect"); err != nil {
+		return err
+	}
+	if !f.Locked {
+		f.Connected = false
+		f.connecting = false
+	}
+	return nil
+}
+
+func (f *FakeClient) Mode() (Mode, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("Mode"); err != nil {
+		return "", err
+	}
+	return f.CurrentMode, nil
+}
+
+func (f *FakeClient) SetMode(mode Mode) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("SetMode"); err != nil {
+		return err
+	}
+	if !f.Locked {
+		f.CurrentMode = mode
+	}
+	return nil
+}
+
+func (f *FakeClient) FamiliesMode() (FamiliesMode, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("FamiliesMode"); err != nil {
+		return "", err
+	}
+	return f.Families, nil
+}
+
+func (f *FakeClient) SetFamiliesMode(mode FamiliesMode) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("SetFamiliesMode"); err != nil {
+		return err
+	}
+	if !f.Locked {
+		f.Families = mode
+	}
+	return nil
+}
+
+func (f *FakeClient) Settings() (map[string]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("Settings"); err != nil {
+		return nil, err
+	}
+	settings := maps.Clone(f.ExtraSettings)
+	if settings == nil {
+		settings = make(map[string]string)
+	}
+	settings["Mode"] = string(f.CurrentMode)
+	settings[familiesSettingKey] = string(f.Families)
+	return settings, nil
+}
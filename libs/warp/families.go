@@ -0,0 +1,125 @@
+package warp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ezydark/ezforce/app/config"
+	"github.com/ezydark/ezforce/libs/events"
+)
+
+// Cloudflare for Families DNS filtering level
+type FamiliesMode string
+
+const (
+	FamiliesOff     FamiliesMode = "off"
+	FamiliesMalware FamiliesMode = "malware"
+	FamiliesFull    FamiliesMode = "full"
+)
+
+var FamiliesModes = []FamiliesMode{FamiliesOff, FamiliesMalware, FamiliesFull}
+
+// Filtering strength of the mode, higher filters more
+func (m FamiliesMode) Level() int {
+	switch m {
+	case FamiliesMalware:
+		return 1
+	case FamiliesFull:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// Convert the families mode printed by 'warp-cli settings' (e.g. "MalwareAndAdult")
+// or a 'warp-cli dns families' argument into a FamiliesMode
+func ParseFamiliesMode(s string) (FamiliesMode, error) {
+	normalized := strings.ToLower(strings.TrimSpace(s))
+	switch {
+	case normalized == "off", normalized == "none", normalized == "disabled", normalized == "":
+		return FamiliesOff, nil
+	case normalized == "full", strings.Contains(normalized, "adult"):
+		return FamiliesFull, nil
+	case strings.Contains(normalized, "malware"):
+		return FamiliesMalware, nil
+	}
+	return "", fmt.Errorf("unknown Warp families mode '%s'", s)
+}
+
+// Key of the families mode in the 'warp-cli settings' output
+const familiesSettingKey = "Families mode"
+
+// Find the families mode in the 'warp-cli settings' key/value pairs. Other
+// settings mentioning families, e.g. "Families mode on trusted networks", are ignored.
+func familiesModeFromSettings(settings map[string]string) (FamiliesMode, error) {
+	for key, value := range settings {
+		if strings.EqualFold(key, familiesSettingKey) {
+			return ParseFamiliesMode(value)
+		}
+	}
+	// Not listed when it was never changed from its default
+	return FamiliesOff, nil
+}
+
+// Families mode required by the config
+func RequiredFamiliesMode() (FamiliesMode, error) {
+	required, err := ParseFamiliesMode(config.Warp().FamiliesMode)
+	if err != nil {
+		return "", fmt.Errorf("invalid required Warp families mode in config:\n %w", err)
+	}
+	return required, nil
+}
+
+// Check if Warp filters DNS at least at the families level required by the config
+func HasRequiredFamiliesMode() (bool, FamiliesMode, error) {
+	current, err := Client.FamiliesMode()
+	if err != nil {
+		return false, "", fmt.Errorf("could not get current Warp families mode:\n %w", err)
+	}
+
+	required, err := RequiredFamiliesMode()
+	if err != nil {
+		return false, current, err
+	}
+	return current.Level() >= required.Level(), current, nil
+}
+
+// Ensure that the Warp families mode was not downgraded below the one required by the config
+func EnsureFamiliesMode() (err error) {
+	// Spellings like "MalwareAndAdult" are passed to warp-cli by their argument name
+	required, parseErr := RequiredFamiliesMode()
+	desired := string(required)
+	if parseErr != nil {
+		desired = config.Warp().FamiliesMode
+	}
+	ev := events.Begin("warp", events.StepFamilies, desired)
+	defer func() { ev.End(err) }()
+	if parseErr != nil {
+		return parseErr
+	}
+
+	hasMode, current, err := HasRequiredFamiliesMode()
+	if err != nil {
+		return err
+	}
+	ev.Observe(string(current))
+	if hasMode {
+		return nil
+	}
+
+	ev.Act(events.ActionSetFamilies)
+	err = Client.SetFamiliesMode(required)
+	if err != nil {
+		return fmt.Errorf("could not set Warp families mode to '%v':\n %w", required, err)
+	}
+
+	ev.Verify()
+	hasMode, current, err = HasRequiredFamiliesMode()
+	if err != nil {
+		return err
+	}
+	if !hasMode {
+		return fmt.Errorf("Warp families mode is still '%v' after setting it to '%v'", current, required)
+	}
+	return nil
+}
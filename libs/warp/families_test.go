@@ -0,0 +1,54 @@
+package warp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ezydark/ezforce/libs/events"
+	"github.com/ezydark/ezforce/libs/events/eventstest"
+)
+
+func TestFamiliesModeFromSettings(t *testing.T) {
+	tests := []struct {
+		file string
+		want FamiliesMode
+	}{
+		{"families_full.txt", FamiliesFull},
+		{"families_default.txt", FamiliesOff},
+		// Other keys mentioning families must not be mistaken for the mode
+		{"families_second_key.txt", FamiliesMalware},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			out, err := os.ReadFile(filepath.Join("testdata", "settings", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Map iteration order differs between runs, the result must not
+			for i := 0; i < 20; i++ {
+				mode, err := familiesModeFromSettings(parseSettings(string(out)))
+				if err != nil || mode != tt.want {
+					t.Fatalf("familiesModeFromSettings() = %q, %v, want %q", mode, err, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// The required level is passed to warp-cli by its argument name, whatever its spelling in the config
+func TestEnsureFamiliesModeParsesRequiredMode(t *testing.T) {
+	requirePolicy(t, ModeWarp, "MalwareAndAdult")
+	fake, _ := useFakeClient(t)
+	fake.Families = FamiliesOff
+	recorder := eventstest.Record(t)
+
+	tt := eventstest.Case[*FakeClient]{Observed: "off", Action: events.ActionSetFamilies, ErrClass: events.ErrorNone}
+	err := EnsureFamiliesMode()
+	eventstest.Check(t, recorder, err, "warp", events.StepFamilies, string(FamiliesFull), tt)
+	if fake.Families != FamiliesFull {
+		t.Errorf("families mode = %q, want %q", fake.Families, FamiliesFull)
+	}
+}
@@ -0,0 +1,121 @@
+package warp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ezydark/ezforce/app/config"
+	"github.com/ezydark/ezforce/libs/events"
+)
+
+// Warp mode, named after the 'warp-cli mode' arguments
+type Mode string
+
+const (
+	ModeWarp       Mode = "warp"
+	ModeDoH        Mode = "doh"
+	ModeDoT        Mode = "dot"
+	ModeWarpDoH    Mode = "warp+doh"
+	ModeWarpDoT    Mode = "warp+dot"
+	ModeProxy      Mode = "proxy"
+	ModeTunnelOnly Mode = "tunnel_only"
+)
+
+var Modes = []Mode{ModeWarp, ModeDoH, ModeDoT, ModeWarpDoH, ModeWarpDoT, ModeProxy, ModeTunnelOnly}
+
+// Convert the mode printed by 'warp-cli settings' (e.g. "WarpWithDnsOverHttps")
+// or a 'warp-cli mode' argument into a Mode
+func ParseMode(s string) (Mode, error) {
+	s = strings.TrimSpace(s)
+	for _, mode := range Modes {
+		if strings.EqualFold(s, string(mode)) {
+			return mode, nil
+		}
+	}
+
+	// Proxy mode is printed together with its port, e.g. "WarpProxy on port 40000"
+	name, _, _ := strings.Cut(s, " ")
+	switch strings.ToLower(name) {
+	case "warp":
+		return ModeWarp, nil
+	case "dnsoverhttps", "doh":
+		return ModeDoH, nil
+	case "dnsovertls", "dot":
+		return ModeDoT, nil
+	case "warpwithdnsoverhttps":
+		return ModeWarpDoH, nil
+	case "warpwithdnsovertls":
+		return ModeWarpDoT, nil
+	case "warpproxy", "proxy":
+		return ModeProxy, nil
+	case "tunnelonly":
+		return ModeTunnelOnly, nil
+	}
+	return "", fmt.Errorf("unknown Warp mode '%s'", s)
+}
+
+// Mode required by the config, empty when no mode is enforced
+func RequiredMode() (Mode, error) {
+	if config.Warp().RequiredMode == "" {
+		return "", nil
+	}
+	required, err := ParseMode(config.Warp().RequiredMode)
+	if err != nil {
+		return "", fmt.Errorf("invalid required Warp mode in config:\n %w", err)
+	}
+	return required, nil
+}
+
+// Check if Warp runs in the mode required by the config
+func IsInRequiredMode() (bool, Mode, error) {
+	current, err := Client.Mode()
+	if err != nil {
+		return false, "", fmt.Errorf("could not get current Warp mode:\n %w", err)
+	}
+
+	required, err := RequiredMode()
+	if err != nil {
+		return false, current, err
+	}
+	return required == "" || current == required, current, nil
+}
+
+// Ensure that Warp runs in the mode required by the config, switching it back when it drifted
+func EnsureMode() (err error) {
+	// Aliases like "WarpWithDnsOverHttps" are passed to warp-cli by their argument name
+	required, parseErr := RequiredMode()
+	desired := string(required)
+	if parseErr != nil {
+		desired = config.Warp().RequiredMode
+	}
+	ev := events.Begin("warp", events.StepMode, desired)
+	defer func() { ev.End(err) }()
+	if parseErr != nil {
+		return parseErr
+	}
+
+	inMode, current, err := IsInRequiredMode()
+	if err != nil {
+		return err
+	}
+	ev.Observe(string(current))
+	if inMode {
+		return nil
+	}
+
+	ev.Act(events.ActionSetMode)
+	err = Client.SetMode(required)
+	if err != nil {
+		return fmt.Errorf("could not switch Warp mode to '%v':\n %w", required, err)
+	}
+
+	ev.Verify()
+	inMode, current, err = IsInRequiredMode()
+	if err != nil {
+		return err
+	}
+	if !inMode {
+		return fmt.Errorf("Warp mode is still '%v' after switching it to '%v'", current, required)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package serv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ezydark/ezforce/app/config"
+	"github.com/ezydark/ezforce/libs/events"
+)
+
+// Name of the file holding the known-good Warp service config, next to the install path
+const BaselineName = "warp-service.json"
+
+// A service config field that differs from the known-good one
+type FieldDiff struct {
+	Field    string
+	Expected string
+	Actual   string
+}
+
+// Fields of the config that differ from the expected one
+func (c ServiceConfig) Diff(expected ServiceConfig) []FieldDiff {
+	var diffs []FieldDiff
+	add := func(field string, expected string, actual string) {
+		if expected != actual {
+			diffs = append(diffs, FieldDiff{Field: field, Expected: expected, Actual: actual})
+		}
+	}
+
+	add("DisplayName", expected.DisplayName, c.DisplayName)
+	add("Description", expected.Description, c.Description)
+	add("BinaryPathName", expected.BinaryPathName, c.BinaryPathName)
+	add("ServiceStartName", expected.ServiceStartName, c.ServiceStartName)
+	// The service managers do not report the dependencies in a stable order
+	add("Dependencies", sortedList(expected.Dependencies), sortedList(c.Dependencies))
+	add("StartType", string(expected.StartType), string(c.StartType))
+	add("DelayedAutoStart", fmt.Sprint(expected.DelayedAutoStart), fmt.Sprint(c.DelayedAutoStart))
+	return diffs
+}
+
+func sortedList(values []string) string {
+	return strings.Join(slices.Sorted(slices.Values(values)), ",")
+}
+
+func BaselinePath() string {
+	return filepath.Join(config.App().InstallPath, BaselineName)
+}
+
+// Snapshot the current Warp service config as the known-good one, meant to be
+// called at install time. The snapshot always starts automatically.
+func (s *WarpServ) SaveBaseline() (ServiceConfig, error) {
+	baseline, err := s.Ctrl.Config()
+	if err != nil {
+		return ServiceConfig{}, err
+	}
+	baseline.StartType = StartAutomatic
+
+	data, err := json.MarshalIndent(baseline, "", "  ")
+	if err != nil {
+		return ServiceConfig{}, fmt.Errorf("failed to encode Warp service baseline:\n %w", err)
+	}
+
+	path := BaselinePath()
+	if err = os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return ServiceConfig{}, fmt.Errorf("failed to write Warp service baseline '%s':\n %w", path, err)
+	}
+	return baseline, nil
+}
+
+// Known-good Warp service config saved at install time
+func LoadBaseline() (ServiceConfig, error) {
+	path := BaselinePath()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ServiceConfig{}, fmt.Errorf("failed to read Warp service baseline:\n %w", err)
+	}
+
+	var baseline ServiceConfig
+	if err = json.Unmarshal(data, &baseline); err != nil {
+		return ServiceConfig{}, fmt.Errorf("failed to parse Warp service baseline '%s':\n %w", path, err)
+	}
+	return baseline, nil
+}
+
+// Ensure that the Warp service config matches the known-good one, restoring every drifted field
+func (s *WarpServ) EnsureConfig() (err error) {
+	ev := events.Begin("warp/serv", events.StepServiceConfig, "baseline")
+	defer func() { ev.End(err) }()
+
+	// Trusting the current config would accept whatever drift removed the baseline
+	baseline, err := LoadBaseline()
+	if errors.Is(err, os.ErrNotExist) {
+		ev.Observe("no baseline")
+		log.Error().Bool("alert", true).
+			Str("service", config.Warp().ServiceName).
+			Msgf("No Warp service baseline found at '%s', reinstall ezForce to create it", BaselinePath())
+		return fmt.Errorf("Warp service baseline is missing, it is only created by 'install':\n %w", err)
+	}
+	if err != nil {
+		return err
+	}
+
+	current, err := s.Ctrl.Config()
+	if err != nil {
+		return err
+	}
+
+	diffs := current.Diff(baseline)
+	if len(diffs) == 0 {
+		ev.Observe("baseline")
+		return nil
+	}
+
+	observed := make([]string, len(diffs))
+	for i, diff := range diffs {
+		log.Warn().
+			Str("service", config.Warp().ServiceName).
+			Str("field", diff.Field).
+			Str("expected", diff.Expected).
+			Str("actual", diff.Actual).
+			Msg("Warp service config drifted")
+		observed[i] = fmt.Sprintf("%s=%s", diff.Field, diff.Actual)
+	}
+	ev.Observe(strings.Join(observed, ", "))
+
+	ev.Act(events.ActionRestoreConfig)
+	err = s.Ctrl.UpdateConfig(baseline)
+	if err != nil {
+		return err
+	}
+
+	ev.Verify()
+	current, err = s.Ctrl.Config()
+	if err != nil {
+		return err
+	}
+	if diffs = current.Diff(baseline); len(diffs) > 0 {
+		return fmt.Errorf("Warp service config still differs in '%s' after restoring it", diffs[0].Field)
+	}
+	return nil
+}
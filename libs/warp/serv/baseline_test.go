@@ -0,0 +1,72 @@
+package serv
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/ezydark/ezforce/app/config"
+)
+
+// Keep the baseline in a temporary install path
+func useInstallPath(t *testing.T) {
+	t.Helper()
+	t.Setenv("EZFORCE_APP_INSTALLPATH", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if err := config.Load(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiffIgnoresDependencyOrder(t *testing.T) {
+	expected := ServiceConfig{BinaryPathName: "warp-svc", Dependencies: []string{"nsi", "dnscache"}}
+	current := ServiceConfig{BinaryPathName: "warp-svc", Dependencies: []string{"dnscache", "nsi"}}
+	if diffs := current.Diff(expected); len(diffs) != 0 {
+		t.Errorf("Diff() = %v, want no difference", diffs)
+	}
+
+	current.Dependencies = []string{"dnscache"}
+	diffs := current.Diff(expected)
+	want := []FieldDiff{{Field: "Dependencies", Expected: "dnscache,nsi", Actual: "dnscache"}}
+	if !slices.Equal(diffs, want) {
+		t.Errorf("Diff() = %v, want %v", diffs, want)
+	}
+}
+
+func TestEnsureConfigWithoutBaseline(t *testing.T) {
+	useInstallPath(t)
+	ctrl := NewFakeController()
+	s := New(ctrl)
+
+	err := s.EnsureConfig()
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("EnsureConfig() error = %v, want the missing baseline", err)
+	}
+	if _, err := os.Stat(BaselinePath()); !errors.Is(err, os.ErrNotExist) {
+		t.Error("EnsureConfig() created the baseline, only install may")
+	}
+	if slices.Contains(ctrl.Calls, "UpdateConfig") {
+		t.Error("EnsureConfig() changed the service without a baseline")
+	}
+}
+
+func TestEnsureConfigRestoresBaseline(t *testing.T) {
+	useInstallPath(t)
+	ctrl := NewFakeController()
+	s := New(ctrl)
+
+	baseline, err := s.SaveBaseline()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctrl.Cfg.BinaryPathName = "/tmp/evil"
+
+	if err := s.EnsureConfig(); err != nil {
+		t.Fatalf("EnsureConfig() error = %v", err)
+	}
+	current, _ := ctrl.Config()
+	if diffs := current.Diff(baseline); len(diffs) != 0 {
+		t.Errorf("config still differs after EnsureConfig(): %v", diffs)
+	}
+}
@@ -0,0 +1,57 @@
+package serv
+
+import (
+	"context"
+	"strings"
+)
+
+// How a service is started by the system
+type StartType string
+
+const (
+	StartAutomatic StartType = "automatic"
+	StartManual    StartType = "manual"
+	StartDisabled  StartType = "disabled"
+)
+
+// Platform independent subset of a service's configuration
+type ServiceConfig struct {
+	DisplayName      string
+	Description      string
+	BinaryPathName   string
+	ServiceStartName string
+	Dependencies     []string
+	StartType        StartType
+	DelayedAutoStart bool
+}
+
+func (c ServiceConfig) String() string {
+	return "start=" + string(c.StartType) + " binary='" + c.BinaryPathName + "' account='" + c.ServiceStartName +
+		"' dependencies=[" + strings.Join(c.Dependencies, ",") + "]"
+}
+
+// Backend managing a single system service, e.g. the Windows SCM or systemd
+type ServiceController interface {
+	// Check if the service starts with the system
+	IsEnabled() (bool, error)
+	// Make the service start with the system
+	Enable() error
+	// Check if the service is running
+	IsRunning() (bool, error)
+	// Ask the system to start the service, without waiting for it to run
+	Start() error
+	// Ask the system to stop the service, without waiting for it to stop
+	Stop() error
+	// Current configuration of the service
+	Config() (ServiceConfig, error)
+	// Replace the configuration of the service
+	UpdateConfig(cfg ServiceConfig) error
+	// Current restart-on-failure policy of the service
+	RecoveryPolicy() (RecoveryPolicy, error)
+	// Replace the restart-on-failure policy of the service
+	SetRecoveryPolicy(policy RecoveryPolicy) error
+	// Deliver the state transitions of the service as they happen, until ctx is done
+	Watch(ctx context.Context) (<-chan StateChange, error)
+	// Release the connection to the service manager
+	Close() error
+}
@@ -0,0 +1,12 @@
+//go:build !windows && !linux
+
+package serv
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func newController(name string) (ServiceController, error) {
+	return nil, fmt.Errorf("managing service '%s' is not supported on %s", name, runtime.GOOS)
+}
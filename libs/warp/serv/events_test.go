@@ -0,0 +1,117 @@
+package serv
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ezydark/ezforce/libs/events"
+	"github.com/ezydark/ezforce/libs/events/eventstest"
+	"github.com/ezydark/ezforce/libs/wait"
+)
+
+// Fake Warp service whose waits do not sleep, with its events recorded
+func useFakeController(t *testing.T) (*WarpServ, *FakeController, *events.Recorder) {
+	t.Helper()
+	useInstallPath(t)
+
+	policy := WaitPolicy
+	t.Cleanup(func() { WaitPolicy = policy })
+	WaitPolicy.Clock = wait.NewFakeClock()
+	WaitPolicy.Jitter = 0
+
+	ctrl := NewFakeController()
+	return New(ctrl), ctrl, eventstest.Record(t)
+}
+
+func TestEnsureIsEnabledEvents(t *testing.T) {
+	tests := []eventstest.Case[*FakeController]{
+		{Name: "already correct", Setup: func(f *FakeController) {}, Observed: "enabled", Action: events.ActionNone, ErrClass: events.ErrorNone},
+		{Name: "corrected", Setup: func(f *FakeController) { f.Enabled = false }, Observed: "disabled", Action: events.ActionEnable, ErrClass: events.ErrorNone},
+		{Name: "query failed", Setup: func(f *FakeController) { f.Err = eventstest.ErrFake }, Observed: "", Action: events.ActionNone, ErrClass: events.ErrorQuery},
+		{Name: "action failed", Setup: func(f *FakeController) { f.Enabled, f.Errs = false, map[string]error{"Enable": eventstest.ErrFake} }, Observed: "disabled", Action: events.ActionEnable, ErrClass: events.ErrorAction},
+		{Name: "timeout", Setup: func(f *FakeController) { f.Enabled, f.Locked = false, true }, Observed: "disabled", Action: events.ActionEnable, ErrClass: events.ErrorTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			s, ctrl, recorder := useFakeController(t)
+			ctrl.Enabled = true
+			tt.Setup(ctrl)
+
+			err := s.EnsureIsEnabled(context.Background())
+			eventstest.Check(t, recorder, err, "warp/serv", events.StepEnabled, "enabled", tt)
+		})
+	}
+}
+
+func TestEnsureIsRunningEvents(t *testing.T) {
+	tests := []eventstest.Case[*FakeController]{
+		{Name: "already correct", Setup: func(f *FakeController) {}, Observed: "running", Action: events.ActionNone, ErrClass: events.ErrorNone},
+		{Name: "corrected", Setup: func(f *FakeController) { f.Running, f.StartAfter = false, 2 }, Observed: "stopped", Action: events.ActionStart, ErrClass: events.ErrorNone},
+		{Name: "query failed", Setup: func(f *FakeController) { f.Err = eventstest.ErrFake }, Observed: "", Action: events.ActionNone, ErrClass: events.ErrorQuery},
+		{Name: "action failed", Setup: func(f *FakeController) { f.Running, f.Errs = false, map[string]error{"Start": eventstest.ErrFake} }, Observed: "stopped", Action: events.ActionStart, ErrClass: events.ErrorAction},
+		{Name: "timeout", Setup: func(f *FakeController) { f.Running, f.Locked = false, true }, Observed: "stopped", Action: events.ActionStart, ErrClass: events.ErrorTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			s, ctrl, recorder := useFakeController(t)
+			ctrl.Running = true
+			tt.Setup(ctrl)
+
+			err := s.EnsureIsRunning(context.Background())
+			eventstest.Check(t, recorder, err, "warp/serv", events.StepRunning, "running", tt)
+		})
+	}
+}
+
+func TestEnsureRecoveryActionsEvents(t *testing.T) {
+	var none RecoveryPolicy
+	tests := []eventstest.Case[*FakeController]{
+		{Name: "already correct", Setup: func(f *FakeController) {}, Observed: RequiredRecoveryPolicy().String(), Action: events.ActionNone, ErrClass: events.ErrorNone},
+		{Name: "corrected", Setup: func(f *FakeController) { f.Recovery = none }, Observed: none.String(), Action: events.ActionSetRecovery, ErrClass: events.ErrorNone},
+		{Name: "query failed", Setup: func(f *FakeController) { f.Err = eventstest.ErrFake }, Observed: "", Action: events.ActionNone, ErrClass: events.ErrorQuery},
+		{Name: "action failed", Setup: func(f *FakeController) {
+			f.Recovery, f.Errs = none, map[string]error{"SetRecoveryPolicy": eventstest.ErrFake}
+		}, Observed: none.String(), Action: events.ActionSetRecovery, ErrClass: events.ErrorAction},
+		{Name: "verify failed", Setup: func(f *FakeController) { f.Recovery, f.Locked = none, true }, Observed: none.String(), Action: events.ActionSetRecovery, ErrClass: events.ErrorVerify},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			s, ctrl, recorder := useFakeController(t)
+			ctrl.Recovery = RequiredRecoveryPolicy()
+			tt.Setup(ctrl)
+
+			err := s.EnsureRecoveryActions()
+			eventstest.Check(t, recorder, err, "warp/serv", events.StepRecovery, RequiredRecoveryPolicy().String(), tt)
+		})
+	}
+}
+
+func TestEnsureConfigEvents(t *testing.T) {
+	drift := func(f *FakeController) { f.Cfg.BinaryPathName = "/tmp/evil" }
+	tests := []eventstest.Case[*FakeController]{
+		{Name: "already correct", Setup: func(f *FakeController) {}, Observed: "baseline", Action: events.ActionNone, ErrClass: events.ErrorNone},
+		{Name: "corrected", Setup: drift, Observed: "BinaryPathName=/tmp/evil", Action: events.ActionRestoreConfig, ErrClass: events.ErrorNone},
+		{Name: "query failed", Setup: func(f *FakeController) { f.Err = eventstest.ErrFake }, Observed: "", Action: events.ActionNone, ErrClass: events.ErrorQuery},
+		{Name: "action failed", Setup: func(f *FakeController) {
+			drift(f)
+			f.Errs = map[string]error{"UpdateConfig": eventstest.ErrFake}
+		}, Observed: "BinaryPathName=/tmp/evil", Action: events.ActionRestoreConfig, ErrClass: events.ErrorAction},
+		{Name: "verify failed", Setup: func(f *FakeController) {
+			drift(f)
+			f.Locked = true
+		}, Observed: "BinaryPathName=/tmp/evil", Action: events.ActionRestoreConfig, ErrClass: events.ErrorVerify},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			s, ctrl, recorder := useFakeController(t)
+			ctrl.Cfg.StartType = StartAutomatic
+			if _, err := s.SaveBaseline(); err != nil {
+				t.Fatal(err)
+			}
+			tt.Setup(ctrl)
+
+			err := s.EnsureConfig()
+			eventstest.Check(t, recorder, err, "warp/serv", events.StepServiceConfig, "baseline", tt)
+		})
+	}
+}
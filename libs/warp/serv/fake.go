@@ -0,0 +1,219 @@
+package serv
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// In-memory ServiceController used in place of a real service manager
+type FakeController struct {
+	mu sync.Mutex
+
+	Enabled bool
+	Running bool
+	// Number of IsRunning calls after Start before the fake reports running
+	StartAfter int
+	Cfg        ServiceConfig
+	Recovery   RecoveryPolicy
+	// Error returned by every call when set
+	Err error
+	// Errors returned by single methods, e.g. "Start", taking precedence over Err
+	Errs map[string]error
+	// Accept changes without applying them, like a service protected by its manager
+	Locked bool
+	// Names of the methods called, in order
+	Calls []string
+
+	pendingStart int
+	starting     bool
+	watchers     []chan StateChange
+}
+
+func NewFakeController() *FakeController {
+	return &FakeController{
+		Cfg: ServiceConfig{
+			DisplayName:    "Fake WARP",
+			BinaryPathName: "warp-svc",
+			StartType:      StartManual,
+		},
+	}
+}
+
+func (f *FakeController) call(name string) error {
+	f.Calls = append(f.Calls, name)
+	if err := f.Errs[name]; err != nil {
+		return err
+	}
+	return f.Err
+}
+
+func (f *FakeController) IsEnabled() (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("IsEnabled"); err != nil {
+		return false, err
+	}
+	return f.Enabled, nil
+}
+
+func (f *FakeController) Enable() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("Enable"); err != nil {
+		return err
+	}
+	if f.Locked {
+		return nil
+	}
+	f.Enabled = true
+	f.Cfg.StartType = StartAutomatic
+	return nil
+}
+
+func (f *FakeController) IsRunning() (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("IsRunning"); err != nil {
+		return false, err
+	}
+	if f.starting {
+		if f.pendingStart > 0 {
+			f.pendingStart--
+			return false, nil
+		}
+		f.starting = false
+		f.setRunning(true)
+	}
+	return f.Running, nil
+}
+
+func (f *FakeController) Start() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("Start"); err != nil {
+		return err
+	}
+	if f.Locked {
+		return nil
+	}
+	if !f.Running {
+		f.starting = true
+		f.pendingStart = f.StartAfter
+		f.notify(StateStopped, StateStartPending)
+	}
+	return nil
+}
+
+func (f *FakeController) Stop() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("Stop"); err != nil {
+		return err
+	}
+	f.starting = false
+	f.setRunning(false)
+	return nil
+}
+
+func (f *FakeController) Config() (ServiceConfig, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("Config"); err != nil {
+		return ServiceConfig{}, err
+	}
+	cfg := f.Cfg
+	cfg.Dependencies = slices.Clone(f.Cfg.Dependencies)
+	return cfg, nil
+}
+
+func (f *FakeController) UpdateConfig(cfg ServiceConfig) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("UpdateConfig"); err != nil {
+		return err
+	}
+	if f.Locked {
+		return nil
+	}
+	f.Cfg = cfg
+	f.Cfg.Dependencies = slices.Clone(cfg.Dependencies)
+	f.Enabled = cfg.StartType == StartAutomatic
+	return nil
+}
+
+func (f *FakeController) RecoveryPolicy() (RecoveryPolicy, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("RecoveryPolicy"); err != nil {
+		return RecoveryPolicy{}, err
+	}
+	policy := f.Recovery
+	policy.RestartDelays = slices.Clone(f.Recovery.RestartDelays)
+	return policy, nil
+}
+
+func (f *FakeController) SetRecoveryPolicy(policy RecoveryPolicy) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("SetRecoveryPolicy"); err != nil {
+		return err
+	}
+	if f.Locked {
+		return nil
+	}
+	f.Recovery = policy
+	f.Recovery.RestartDelays = slices.Clone(policy.RestartDelays)
+	return nil
+}
+
+func (f *FakeController) Watch(ctx context.Context) (<-chan StateChange, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("Watch"); err != nil {
+		return nil, err
+	}
+
+	changes := make(chan StateChange, 16)
+	f.watchers = append(f.watchers, changes)
+	go func() {
+		<-ctx.Done()
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.watchers = slices.DeleteFunc(f.watchers, func(c chan StateChange) bool { return c == changes })
+		close(changes)
+	}()
+	return changes, nil
+}
+
+// Simulate the service starting or stopping outside of the controller
+func (f *FakeController) SetRunning(running bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.starting = false
+	f.setRunning(running)
+}
+
+func (f *FakeController) setRunning(running bool) {
+	if f.Running == running {
+		return
+	}
+	f.Running = running
+	if running {
+		f.notify(StateStartPending, StateRunning)
+	} else {
+		f.notify(StateRunning, StateStopped)
+	}
+}
+
+func (f *FakeController) notify(from ServiceState, to ServiceState) {
+	for _, watcher := range f.watchers {
+		sendChange(watcher, from, to)
+	}
+}
+
+func (f *FakeController) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.call("Close")
+}
@@ -0,0 +1,92 @@
+package serv
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ezydark/ezforce/app/config"
+	"github.com/ezydark/ezforce/libs/events"
+)
+
+// Restart-on-failure policy of a service
+type RecoveryPolicy struct {
+	// Delay before each restart after consecutive failures, the last one repeats
+	RestartDelays []time.Duration
+	// Time without failures after which the failure count is reset
+	ResetPeriod time.Duration
+}
+
+func (p RecoveryPolicy) Equal(other RecoveryPolicy) bool {
+	return slices.Equal(p.RestartDelays, other.RestartDelays) && p.ResetPeriod == other.ResetPeriod
+}
+
+func (p RecoveryPolicy) String() string {
+	if len(p.RestartDelays) == 0 {
+		return "no restart on failure"
+	}
+	delays := make([]string, len(p.RestartDelays))
+	for i, delay := range p.RestartDelays {
+		delays[i] = delay.String()
+	}
+	return fmt.Sprintf("restart after %s, reset after %v", strings.Join(delays, ", "), p.ResetPeriod)
+}
+
+// Implemented by controllers that can only express part of a RecoveryPolicy,
+// returns the policy as the controller would report it back once set
+type recoveryNormalizer interface {
+	NormalizeRecoveryPolicy(policy RecoveryPolicy) RecoveryPolicy
+}
+
+// Recovery policy required by the config
+func RequiredRecoveryPolicy() RecoveryPolicy {
+	policy := RecoveryPolicy{
+		ResetPeriod: time.Duration(config.Warp().RestartResetPeriod) * time.Second,
+	}
+	for _, delay := range config.Warp().RestartDelays {
+		policy.RestartDelays = append(policy.RestartDelays, time.Duration(delay)*time.Second)
+	}
+	return policy
+}
+
+// Current restart-on-failure policy of the Warp service
+func (s *WarpServ) RecoveryPolicy() (RecoveryPolicy, error) {
+	return s.Ctrl.RecoveryPolicy()
+}
+
+// Ensure that the system restarts the Warp service on failure as required by the config
+func (s *WarpServ) EnsureRecoveryActions() (err error) {
+	required := RequiredRecoveryPolicy()
+	if normalizer, ok := s.Ctrl.(recoveryNormalizer); ok {
+		required = normalizer.NormalizeRecoveryPolicy(required)
+	}
+
+	ev := events.Begin("warp/serv", events.StepRecovery, required.String())
+	defer func() { ev.End(err) }()
+
+	current, err := s.Ctrl.RecoveryPolicy()
+	if err != nil {
+		return err
+	}
+	ev.Observe(current.String())
+	if current.Equal(required) {
+		return nil
+	}
+
+	ev.Act(events.ActionSetRecovery)
+	err = s.Ctrl.SetRecoveryPolicy(required)
+	if err != nil {
+		return err
+	}
+
+	ev.Verify()
+	current, err = s.Ctrl.RecoveryPolicy()
+	if err != nil {
+		return err
+	}
+	if !current.Equal(required) {
+		return fmt.Errorf("Warp service recovery policy is still '%v' after setting it to '%v'", current, required)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package serv
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Directory holding the admin's unit drop-ins
+const systemdUnitDir = "/etc/systemd/system"
+
+// Name of the drop-in holding the restart policy
+const recoveryDropInName = "ezforce-recovery.conf"
+
+// Number of restarts over which the delay grows from the first to the last one
+const recoveryRestartSteps = 5
+
+// First systemd version knowing RestartSteps and RestartMaxDelaySec
+const restartStepsVersion = 254
+
+func (c *SystemdController) recoveryDropInPath() string {
+	return filepath.Join(systemdUnitDir, c.Unit+".d", recoveryDropInName)
+}
+
+// systemd restarts with the first delay, growing up to the last one, and its
+// start rate limit is disabled so it never gives up restarting. Before version
+// 254 the delay cannot grow, so the first one is kept for every restart.
+// systemd has no failure count to reset, so the reset period is dropped.
+func (c *SystemdController) NormalizeRecoveryPolicy(policy RecoveryPolicy) RecoveryPolicy {
+	normalized := RecoveryPolicy{}
+	switch {
+	case len(policy.RestartDelays) == 0:
+	case len(policy.RestartDelays) == 1 || c.Version < restartStepsVersion:
+		normalized.RestartDelays = []time.Duration{policy.RestartDelays[0]}
+	default:
+		normalized.RestartDelays = []time.Duration{policy.RestartDelays[0], policy.RestartDelays[len(policy.RestartDelays)-1]}
+	}
+	return normalized
+}
+
+func (c *SystemdController) RecoveryPolicy() (RecoveryPolicy, error) {
+	props, err := c.show("Restart", "RestartUSec", "RestartSteps", "RestartMaxDelayUSec")
+	if err != nil {
+		return RecoveryPolicy{}, err
+	}
+
+	policy := RecoveryPolicy{}
+	if props["Restart"] != "always" {
+		return policy, nil
+	}
+
+	delay, err := parseTimespan(props["RestartUSec"])
+	if err != nil {
+		return RecoveryPolicy{}, err
+	}
+	policy.RestartDelays = []time.Duration{delay}
+
+	if steps, _ := strconv.Atoi(props["RestartSteps"]); steps > 0 {
+		maxDelay, err := parseTimespan(props["RestartMaxDelayUSec"])
+		if err != nil {
+			return RecoveryPolicy{}, err
+		}
+		policy.RestartDelays = append(policy.RestartDelays, maxDelay)
+	}
+	return policy, nil
+}
+
+// Write the restart policy as a drop-in of the unit and reload systemd
+func (c *SystemdController) SetRecoveryPolicy(policy RecoveryPolicy) error {
+	path := c.recoveryDropInPath()
+	policy = c.NormalizeRecoveryPolicy(policy)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create drop-in directory:\n %w", err)
+	}
+	if err := os.WriteFile(path, []byte(recoveryDropIn(policy)), 0644); err != nil {
+		return fmt.Errorf("failed to write drop-in '%s':\n %w", path, err)
+	}
+
+	_, err := c.systemctl("daemon-reload")
+	return err
+}
+
+// Drop-in of the restart policy. Without delays restarting is turned off, the
+// unit's own Restart= would otherwise be reported back as a different policy.
+func recoveryDropIn(policy RecoveryPolicy) string {
+	var b strings.Builder
+	b.WriteString("# Managed by ezForce, changes are overwritten\n")
+	if len(policy.RestartDelays) == 0 {
+		b.WriteString("[Service]\n")
+		b.WriteString("Restart=no\n")
+		return b.String()
+	}
+	b.WriteString("[Unit]\n")
+	b.WriteString("StartLimitIntervalSec=0\n")
+	b.WriteString("\n[Service]\n")
+	b.WriteString("Restart=always\n")
+	fmt.Fprintf(&b, "RestartSec=%dms\n", policy.RestartDelays[0].Milliseconds())
+	if len(policy.RestartDelays) > 1 {
+		fmt.Fprintf(&b, "RestartSteps=%d\n", recoveryRestartSteps)
+		fmt.Fprintf(&b, "RestartMaxDelaySec=%dms\n", policy.RestartDelays[len(policy.RestartDelays)-1].Milliseconds())
+	}
+	return b.String()
+}
+
+// Parse a systemd time span as printed by 'systemctl show', e.g. "1min 30s" or "100ms"
+func parseTimespan(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return 0, nil
+	}
+	if s == "infinity" {
+		return 0, nil
+	}
+
+	units := []struct {
+		suffix string
+		unit   time.Duration
+	}{
+		{"us", time.Microsecond},
+		{"ms", time.Millisecond},
+		{"min", time.Minute},
+		{"s", time.Second},
+		{"h", time.Hour},
+		{"d", 24 * time.Hour},
+		{"w", 7 * 24 * time.Hour},
+	}
+
+	var total time.Duration
+	for _, part := range strings.Fields(s) {
+		parsed := false
+		for _, u := range units {
+			number, found := strings.CutSuffix(part, u.suffix)
+			if !found {
+				continue
+			}
+			value, err := strconv.ParseFloat(number, 64)
+			if err != nil {
+				continue
+			}
+			total += time.Duration(value * float64(u.unit))
+			parsed = true
+			break
+		}
+		if !parsed {
+			return 0, fmt.Errorf("invalid systemd time span '%s'", s)
+		}
+	}
+	return total, nil
+}
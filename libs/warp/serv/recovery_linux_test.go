@@ -0,0 +1,150 @@
+package serv
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSystemdVersion(t *testing.T) {
+	tests := []struct {
+		out     string
+		version int
+		valid   bool
+	}{
+		{"systemd 252 (252.22-1~deb12u1)\n+PAM +AUDIT +SELINUX\n", 252, true},
+		{"systemd 255 (255.4-1ubuntu8)\n", 255, true},
+		{"systemd 219\n", 219, true},
+		{"", 0, false},
+		{"systemd\n", 0, false},
+		{"systemd v256\n", 0, false},
+	}
+	for _, tt := range tests {
+		version, err := parseSystemdVersion(tt.out)
+		if (err == nil) != tt.valid || version != tt.version {
+			t.Errorf("parseSystemdVersion(%q) = %d, %v, want %d", tt.out, version, err, tt.version)
+		}
+	}
+}
+
+func TestNormalizeRecoveryPolicy(t *testing.T) {
+	policy := RecoveryPolicy{
+		RestartDelays: []time.Duration{5 * time.Second, 10 * time.Second, 30 * time.Second},
+		ResetPeriod:   24 * time.Hour,
+	}
+	tests := []struct {
+		version int
+		want    []time.Duration
+	}{
+		{0, []time.Duration{5 * time.Second}},
+		{252, []time.Duration{5 * time.Second}},
+		{254, []time.Duration{5 * time.Second, 30 * time.Second}},
+	}
+	for _, tt := range tests {
+		c := &SystemdController{Unit: "warp-svc.service", Version: tt.version}
+		normalized := c.NormalizeRecoveryPolicy(policy)
+		if !slices.Equal(normalized.RestartDelays, tt.want) || normalized.ResetPeriod != 0 {
+			t.Errorf("systemd %d: NormalizeRecoveryPolicy() = %v, want delays %v", tt.version, normalized, tt.want)
+		}
+	}
+
+	c := &SystemdController{Version: 255}
+	if normalized := c.NormalizeRecoveryPolicy(RecoveryPolicy{}); len(normalized.RestartDelays) != 0 {
+		t.Errorf("NormalizeRecoveryPolicy() = %v, want no restart", normalized)
+	}
+}
+
+func TestRecoveryDropIn(t *testing.T) {
+	policy := RecoveryPolicy{RestartDelays: []time.Duration{5 * time.Second, 30 * time.Second}}
+
+	old := &SystemdController{Version: 252}
+	dropIn := recoveryDropIn(old.NormalizeRecoveryPolicy(policy))
+	if !strings.Contains(dropIn, "RestartSec=5000ms\n") {
+		t.Errorf("drop-in without the restart delay:\n%s", dropIn)
+	}
+	if strings.Contains(dropIn, "RestartSteps") || strings.Contains(dropIn, "RestartMaxDelaySec") {
+		t.Errorf("drop-in for systemd 252 uses settings it does not know:\n%s", dropIn)
+	}
+
+	recent := &SystemdController{Version: 254}
+	dropIn = recoveryDropIn(recent.NormalizeRecoveryPolicy(policy))
+	for _, line := range []string{"RestartSec=5000ms\n", "RestartSteps=5\n", "RestartMaxDelaySec=30000ms\n"} {
+		if !strings.Contains(dropIn, line) {
+			t.Errorf("drop-in for systemd 254 without %q:\n%s", line, dropIn)
+		}
+	}
+}
+
+// Put a fake systemctl printing out first on the PATH
+func fakeSystemctl(t *testing.T, out string) {
+	dir := t.TempDir()
+	script := "#!/bin/sh\ncat <<'EOF'\n" + out + "EOF\n"
+	if err := os.WriteFile(filepath.Join(dir, "systemctl"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestRecoveryPolicyWithoutRestart(t *testing.T) {
+	// systemd reports its default restart delay and start limit interval even without restarts
+	fakeSystemctl(t, "Restart=no\nRestartUSec=100ms\nRestartSteps=0\nRestartMaxDelayUSec=infinity\nStartLimitIntervalUSec=10s\n")
+
+	c := &SystemdController{Unit: "warp-svc.service", Version: 255}
+	required := c.NormalizeRecoveryPolicy(RecoveryPolicy{ResetPeriod: 24 * time.Hour})
+	current, err := c.RecoveryPolicy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !current.Equal(required) {
+		t.Errorf("RecoveryPolicy() = %v, want %v", current, required)
+	}
+
+	dropIn := recoveryDropIn(required)
+	if !strings.Contains(dropIn, "Restart=no\n") || strings.Contains(dropIn, "RestartSec") {
+		t.Errorf("drop-in without restarts does not turn them off:\n%s", dropIn)
+	}
+}
+
+func TestRecoveryPolicyIgnoresStartLimit(t *testing.T) {
+	fakeSystemctl(t, "Restart=always\nRestartUSec=5s\nRestartSteps=5\nRestartMaxDelayUSec=30s\nStartLimitIntervalUSec=10s\n")
+
+	c := &SystemdController{Unit: "warp-svc.service", Version: 255}
+	required := c.NormalizeRecoveryPolicy(RecoveryPolicy{
+		RestartDelays: []time.Duration{5 * time.Second, 10 * time.Second, 30 * time.Second},
+		ResetPeriod:   24 * time.Hour,
+	})
+	current, err := c.RecoveryPolicy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !current.Equal(required) {
+		t.Errorf("RecoveryPolicy() = %v, want %v", current, required)
+	}
+}
+
+func TestParseTimespan(t *testing.T) {
+	tests := map[string]time.Duration{
+		"":           0,
+		"infinity":   0,
+		"100ms":      100 * time.Millisecond,
+		"5s":         5 * time.Second,
+		"1min 30s":   90 * time.Second,
+		"1d 2h":      26 * time.Hour,
+		"1.5s":       1500 * time.Millisecond,
+		"500us":      500 * time.Microsecond,
+		"1w":         7 * 24 * time.Hour,
+		"2min 500ms": 2*time.Minute + 500*time.Millisecond,
+	}
+	for s, want := range tests {
+		got, err := parseTimespan(s)
+		if err != nil || got != want {
+			t.Errorf("parseTimespan(%q) = %v, %v, want %v", s, got, err, want)
+		}
+	}
+	if _, err := parseTimespan("soon"); err == nil {
+		t.Error("parseTimespan(\"soon\") succeeded")
+	}
+}
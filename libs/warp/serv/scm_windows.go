@@ -0,0 +1,225 @@
+package serv
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// ServiceController backend using the Windows service control manager
+type SCMController struct {
+	ServMgr *mgr.Mgr
+	Service *mgr.Service
+}
+
+func newController(name string) (ServiceController, error) {
+	return NewSCMController(name)
+}
+
+// Connect to the Windows service manager and open the named service
+func NewSCMController(name string) (*SCMController, error) {
+	manager, err := mgr.Connect()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to service manager:\n %w", err)
+	}
+
+	service, err := manager.OpenService(name)
+	if err != nil {
+		manager.Disconnect()
+		return nil, fmt.Errorf("failed to open service '%s':\n %w", name, err)
+	}
+
+	return &SCMController{
+		ServMgr: manager,
+		Service: service,
+	}, nil
+}
+
+func (c *SCMController) Close() error {
+	if c.Service != nil {
+		c.Service.Close()
+		c.Service = nil
+	}
+	if c.ServMgr != nil {
+		c.ServMgr.Disconnect()
+		c.ServMgr = nil
+	}
+	return nil
+}
+
+func (c *SCMController) IsEnabled() (bool, error) {
+	serv_conf, err := c.Service.Config()
+	if err != nil {
+		return false, fmt.Errorf("failed to get service config:\n %w", err)
+	}
+
+	return serv_conf.StartType == mgr.StartAutomatic, nil
+}
+
+func (c *SCMController) Enable() error {
+	serv_conf, err := c.Service.Config()
+	if err != nil {
+		return fmt.Errorf("failed to get service config:\n %w", err)
+	}
+
+	if serv_conf.StartType == mgr.StartAutomatic {
+		return nil
+	}
+
+	newConfig := mgr.Config{
+		StartType: mgr.StartAutomatic,
+		// Keep other settings the same
+		DisplayName:      serv_conf.DisplayName,
+		Description:      serv_conf.Description,
+		BinaryPathName:   serv_conf.BinaryPathName,
+		LoadOrderGroup:   serv_conf.LoadOrderGroup,
+		Dependencies:     serv_conf.Dependencies,
+		ServiceStartName: serv_conf.ServiceStartName,
+		DelayedAutoStart: serv_conf.DelayedAutoStart,
+		ErrorControl:     serv_conf.ErrorControl,
+		ServiceType:      serv_conf.ServiceType,
+	}
+
+	err = c.Service.UpdateConfig(newConfig)
+	if err != nil {
+		return fmt.Errorf("failed to update service config: %w", err)
+	}
+	return nil
+}
+
+func (c *SCMController) IsRunning() (bool, error) {
+	status, err := c.Service.Query()
+	if err != nil {
+		return false, fmt.Errorf("failed to get service status:\n %w", err)
+	}
+
+	return status.State == svc.Running, nil
+}
+
+func (c *SCMController) Start() error {
+	err := c.Service.Start()
+	if err != nil {
+		return fmt.Errorf("failed to start service: %w", err)
+	}
+	return nil
+}
+
+func (c *SCMController) Stop() error {
+	_, err := c.Service.Control(svc.Stop)
+	if err != nil {
+		return fmt.Errorf("failed to stop service: %w", err)
+	}
+	return nil
+}
+
+func (c *SCMController) Config() (ServiceConfig, error) {
+	serv_conf, err := c.Service.Config()
+	if err != nil {
+		return ServiceConfig{}, fmt.Errorf("failed to get service config:\n %w", err)
+	}
+
+	return ServiceConfig{
+		DisplayName:      serv_conf.DisplayName,
+		Description:      serv_conf.Description,
+		BinaryPathName:   serv_conf.BinaryPathName,
+		ServiceStartName: serv_conf.ServiceStartName,
+		Dependencies:     serv_conf.Dependencies,
+		StartType:        scmStartType(serv_conf.StartType),
+		DelayedAutoStart: serv_conf.DelayedAutoStart,
+	}, nil
+}
+
+func (c *SCMController) UpdateConfig(cfg ServiceConfig) error {
+	serv_conf, err := c.Service.Config()
+	if err != nil {
+		return fmt.Errorf("failed to get service config:\n %w", err)
+	}
+
+	serv_conf.DisplayName = cfg.DisplayName
+	serv_conf.Description = cfg.Description
+	serv_conf.BinaryPathName = cfg.BinaryPathName
+	serv_conf.ServiceStartName = cfg.ServiceStartName
+	serv_conf.Dependencies = cfg.Dependencies
+	serv_conf.StartType = scmStartTypeOf(cfg.StartType)
+	serv_conf.DelayedAutoStart = cfg.DelayedAutoStart
+
+	err = c.Service.UpdateConfig(serv_conf)
+	if err != nil {
+		return fmt.Errorf("failed to update service config: %w", err)
+	}
+
+	// mgr treats an empty dependency list as "no change", clear it explicitly
+	if len(cfg.Dependencies) == 0 {
+		empty := []uint16{0, 0}
+		err = windows.ChangeServiceConfig(c.Service.Handle, windows.SERVICE_NO_CHANGE, windows.SERVICE_NO_CHANGE,
+			windows.SERVICE_NO_CHANGE, nil, nil, nil, &empty[0], nil, nil, nil)
+		if err != nil {
+			return fmt.Errorf("failed to clear service dependencies: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *SCMController) RecoveryPolicy() (RecoveryPolicy, error) {
+	actions, err := c.Service.RecoveryActions()
+	if err != nil {
+		return RecoveryPolicy{}, fmt.Errorf("failed to get service recovery actions:\n %w", err)
+	}
+	resetPeriod, err := c.Service.ResetPeriod()
+	if err != nil {
+		return RecoveryPolicy{}, fmt.Errorf("failed to get service recovery reset period:\n %w", err)
+	}
+
+	policy := RecoveryPolicy{ResetPeriod: time.Duration(resetPeriod) * time.Second}
+	for _, action := range actions {
+		if action.Type != mgr.ServiceRestart {
+			break
+		}
+		policy.RestartDelays = append(policy.RestartDelays, action.Delay)
+	}
+	return policy, nil
+}
+
+func (c *SCMController) SetRecoveryPolicy(policy RecoveryPolicy) error {
+	actions := make([]mgr.RecoveryAction, len(policy.RestartDelays))
+	for i, delay := range policy.RestartDelays {
+		actions[i] = mgr.RecoveryAction{Type: mgr.ServiceRestart, Delay: delay}
+	}
+
+	err := c.Service.SetRecoveryActions(actions, uint32(policy.ResetPeriod/time.Second))
+	if err != nil {
+		return fmt.Errorf("failed to set service recovery actions:\n %w", err)
+	}
+
+	// Also restart when the service stops itself with an error instead of crashing
+	err = c.Service.SetRecoveryActionsOnNonCrashFailures(true)
+	if err != nil {
+		return fmt.Errorf("failed to enable recovery actions on non-crash failures:\n %w", err)
+	}
+	return nil
+}
+
+func scmStartTypeOf(startType StartType) uint32 {
+	switch startType {
+	case StartAutomatic:
+		return mgr.StartAutomatic
+	case StartDisabled:
+		return mgr.StartDisabled
+	default:
+		return mgr.StartManual
+	}
+}
+
+func scmStartType(startType uint32) StartType {
+	switch startType {
+	case mgr.StartAutomatic:
+		return StartAutomatic
+	case mgr.StartDisabled:
+		return StartDisabled
+	default:
+		return StartManual
+	}
+}
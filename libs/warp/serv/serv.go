@@ -1,204 +1,168 @@
 package serv
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"time"
 
 	"github.com/ezydark/ezforce/app/config"
-	"github.com/rs/zerolog/log"
-	"golang.org/x/sys/windows/svc"
-	"golang.org/x/sys/windows/svc/mgr"
+	"github.com/ezydark/ezforce/libs/events"
+	"github.com/ezydark/ezforce/libs/logger"
+	"github.com/ezydark/ezforce/libs/wait"
 )
 
+// Logger of the package, tagged with its component
+var log = logger.Component("warp/serv")
+
+// Warp service managed through the platform's ServiceController
 type WarpServ struct {
-	ServMgr  *mgr.Mgr
-	WarpServ *mgr.Service
+	Ctrl ServiceController
 }
 
-// Initialize the Windows service manager with Warp service
+// Initialize the platform's service manager with Warp service
 func (s *WarpServ) Init() (*WarpServ, error) {
 	if s != nil {
 		return nil, errors.New("warpserv is already initialized")
 	}
 
-	// Connect to the Windows service manager
-	manager, err := mgr.Connect()
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to service manager:\n %w", err)
-	}
-
-	// Open the specific service
-	warp_service_name := config.Warp.ServiceName
-	service, err := manager.OpenService(warp_service_name)
+	ctrl, err := newController(config.Warp().ServiceName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open service '%s':\n %w", warp_service_name, err)
+		return nil, err
 	}
 
-	s = &WarpServ{
-		ServMgr:  manager,
-		WarpServ: service,
-	}
+	return New(ctrl), nil
+}
 
-	return s, nil
+// Manage the Warp service through the given controller
+func New(ctrl ServiceController) *WarpServ {
+	return &WarpServ{Ctrl: ctrl}
 }
 
-// Close the Warp service and Windows service manager
+// Close the Warp service and the service manager
 func (s *WarpServ) Close() error {
-	if s != nil {
-		s.WarpServ.Close()
-		s.WarpServ = nil
-		s.ServMgr.Disconnect()
-		s.ServMgr = nil
+	if s != nil && s.Ctrl != nil {
+		err := s.Ctrl.Close()
+		s.Ctrl = nil
+		return err
 	}
 	return nil
 }
 
 // Ensure that the Warp service is set to startup automatically
-func (s *WarpServ) EnsureIsEnabled() error {
+func (s *WarpServ) EnsureIsEnabled(ctx context.Context) (err error) {
+	ev := events.Begin("warp/serv", events.StepEnabled, "enabled")
+	defer func() { ev.End(err) }()
+
 	enabled, err := s.IsEnabled()
 	if err != nil {
 		return err
 	}
 	if enabled {
+		ev.Observe("enabled")
 		return nil
-	} else {
-		log.Error().Msg("Warp service is not enabled for startup! Trying to enable it...")
-		return s.Enable()
 	}
+
+	ev.Observe("disabled")
+	ev.Act(events.ActionEnable)
+	return s.Enable(ctx)
 }
 
-func (s *WarpServ) EnsureIsRunning() error {
+func (s *WarpServ) EnsureIsRunning(ctx context.Context) (err error) {
+	ev := events.Begin("warp/serv", events.StepRunning, "running")
+	defer func() { ev.End(err) }()
+
 	isRunning, err := s.IsRunning()
 	if err != nil {
 		return err
 	}
 	if isRunning {
+		ev.Observe("running")
 		return nil
-	} else {
-		log.Error().Msg("Warp service is not running! Trying to start it...")
-		return s.Start()
 	}
+
+	ev.Observe("stopped")
+	ev.Act(events.ActionStart)
+	return s.Start(ctx)
 }
 
 // Check if the Warp service is set to startup automatically
 func (s *WarpServ) IsEnabled() (bool, error) {
-	serv_conf, err := s.WarpServ.Config()
-	if err != nil {
-		return false, fmt.Errorf("failed to get service config:\n %w", err)
-	}
-
-	if serv_conf.StartType == mgr.StartAutomatic {
-		return true, nil
-	} else {
-		return false, nil
-	}
+	return s.Ctrl.IsEnabled()
 }
 
-func (s *WarpServ) Enable() error {
-	serv_conf, err := s.WarpServ.Config()
+func (s *WarpServ) Enable(ctx context.Context) error {
+	err := s.Ctrl.Enable()
 	if err != nil {
-		return fmt.Errorf("failed to get service config:\n %w", err)
-	}
-
-	if serv_conf.StartType == mgr.StartAutomatic {
-		return nil
-	}
-
-	newConfig := mgr.Config{
-		StartType: mgr.StartAutomatic,
-		// Keep other settings the same
-		DisplayName:      serv_conf.DisplayName,
-		Description:      serv_conf.Description,
-		BinaryPathName:   serv_conf.BinaryPathName,
-		LoadOrderGroup:   serv_conf.LoadOrderGroup,
-		Dependencies:     serv_conf.Dependencies,
-		ServiceStartName: serv_conf.ServiceStartName,
-		DelayedAutoStart: serv_conf.DelayedAutoStart,
-		ErrorControl:     serv_conf.ErrorControl,
-		ServiceType:      serv_conf.ServiceType,
-	}
-
-	err = s.WarpServ.UpdateConfig(newConfig)
-	if err != nil {
-		return fmt.Errorf("failed to update service config: %w", err)
+		return err
 	}
 
-	return s.waitForWarpServToBeEnabled(20, 500*time.Millisecond)
+	return s.waitForWarpServToBeEnabled(ctx)
 }
 
 // Check if the Warp service's status is running
 func (s *WarpServ) IsRunning() (bool, error) {
-	status, err := s.WarpServ.Query()
-	if err != nil {
-		return false, fmt.Errorf("failed to get service status:\n %w", err)
-	}
-
-	isRunning := status.State == svc.Running
-
-	return isRunning, nil
+	return s.Ctrl.IsRunning()
 }
 
-func (s *WarpServ) Start() error {
-	err := s.WarpServ.Start()
+func (s *WarpServ) Start(ctx context.Context) error {
+	err := s.Ctrl.Start()
 	if err != nil {
-		return fmt.Errorf("failed to start service: %w", err)
+		return err
 	}
 
-	return s.waitForWarpServToBeRunning(20, 500*time.Millisecond)
+	return s.waitForWarpServToBeRunning(ctx)
 }
 
-func (s *WarpServ) waitForWarpServToBeRunning(maxAttempts int, waitTime time.Duration) error {
-	isRunning, err := s.IsRunning()
-	if err != nil {
-		return fmt.Errorf("failed to check Warp service status: %w", err)
-	}
-	if isRunning {
-		return nil
-	}
+// How long to wait for the Warp service to change its state
+var WaitPolicy = wait.Policy{
+	MaxAttempts:  20,
+	InitialDelay: 250 * time.Millisecond,
+	MaxDelay:     2 * time.Second,
+	Multiplier:   1.5,
+	Jitter:       0.1,
+	Timeout:      30 * time.Second,
+}
 
-	for attempt := 1; attempt < maxAttempts; attempt++ {
-		log.Debug().Msgf("[%v/%v] Waiting for '%v' to start...",
-			attempt, maxAttempts, config.Warp.ServiceName)
+// Wait for Warp service to be running
+func (s *WarpServ) waitForWarpServToBeRunning(ctx context.Context) error {
+	err := wait.WaitFor(ctx, func(ctx context.Context) (bool, error) {
 		isRunning, err := s.IsRunning()
 		if err != nil {
-			return fmt.Errorf("failed to check Warp service status: %w", err)
+			return false, fmt.Errorf("failed to check Warp service status: %w", err)
 		}
-		if isRunning {
-			log.Debug().Msgf("'%v' started successfully", config.Warp.ServiceName)
-			return nil
-		}
-		time.Sleep(waitTime)
-	}
-	return fmt.Errorf("failed to start service after %v attempts", maxAttempts)
-}
-
-// Wait for Warp service to start
-func (s *WarpServ) waitForWarpServToBeEnabled(maxAttempts int, waitTime time.Duration) error {
-	enabled, err := s.IsEnabled()
+		return isRunning, nil
+	}, withProgress(WaitPolicy, "start"))
 	if err != nil {
-		return fmt.Errorf("failed to check if Warp service is enabled for startup: %w", err)
-	}
-	if enabled {
-		return nil
+		return fmt.Errorf("failed to start '%v':\n %w", config.Warp().ServiceName, err)
 	}
 
-	for attempt := 1; attempt < maxAttempts; attempt++ {
-		log.Debug().Msgf("[%v/%v] Waiting for '%v' to start...",
-			attempt, maxAttempts, config.Warp.ServiceName)
-		time.Sleep(waitTime)
+	log.Debug().Msgf("'%v' started successfully", config.Warp().ServiceName)
+	return nil
+}
 
-		enabled, err = s.IsEnabled()
+// Wait for Warp service to be enabled for startup
+func (s *WarpServ) waitForWarpServToBeEnabled(ctx context.Context) error {
+	err := wait.WaitFor(ctx, func(ctx context.Context) (bool, error) {
+		enabled, err := s.IsEnabled()
 		if err != nil {
-			return fmt.Errorf("failed to check if Warp service is enabled for startup: %w", err)
-		}
-		if enabled {
-			log.Debug().Msgf("'%v' successfully started after %v attempts",
-				config.Warp.ServiceName, attempt)
-			return nil
+			return false, fmt.Errorf("failed to check if Warp service is enabled for startup: %w", err)
 		}
+		return enabled, nil
+	}, withProgress(WaitPolicy, "be enabled for startup"))
+	if err != nil {
+		return fmt.Errorf("could not enable '%v' for startup:\n %w", config.Warp().ServiceName, err)
 	}
 
-	return fmt.Errorf("could not enable '%v' for startup after %v attempts",
-		config.Warp.ServiceName, maxAttempts)
+	log.Debug().Msgf("'%v' successfully enabled for startup", config.Warp().ServiceName)
+	return nil
+}
+
+// Log each attempt of the wait at debug level
+func withProgress(policy wait.Policy, action string) wait.Policy {
+	policy.OnProgress = func(attempt int, maxAttempts int, next time.Duration) {
+		log.Debug().Msgf("[%v/%v] Waiting %v for '%v' to %v...",
+			attempt, maxAttempts, next.Round(time.Millisecond), config.Warp().ServiceName, action)
+	}
+	return policy
 }
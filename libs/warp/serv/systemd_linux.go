@@ -0,0 +1,259 @@
+package serv
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ServiceController backend running 'systemctl' against a systemd unit
+type SystemdController struct {
+	Unit string
+	// Major version of the running systemd, 0 when unknown
+	Version int
+}
+
+func newController(name string) (ServiceController, error) {
+	return NewSystemdController(name)
+}
+
+// Controller of the named unit, ".service" is appended when missing
+func NewSystemdController(name string) (*SystemdController, error) {
+	if !strings.Contains(name, ".") {
+		name += ".service"
+	}
+
+	c := &SystemdController{Unit: name}
+	props, err := c.show("LoadState")
+	if err != nil {
+		return nil, err
+	}
+	if props["LoadState"] == "not-found" {
+		return nil, fmt.Errorf("unit '%s' is not installed", name)
+	}
+
+	// Older systemd is treated as the oldest one, it only loses the newest unit settings
+	out, err := c.systemctl("--version")
+	if err == nil {
+		c.Version, err = parseSystemdVersion(out)
+	}
+	if err != nil {
+		log.Warn().Msgf("Could not get systemd version, assuming an old one:\n %v", err)
+	}
+	return c, nil
+}
+
+// Major version from the first line of 'systemctl --version', e.g. "systemd 252 (252.22-1~deb12u1)"
+func parseSystemdVersion(out string) (int, error) {
+	line, _, _ := strings.Cut(out, "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 2 || fields[0] != "systemd" {
+		return 0, fmt.Errorf("unexpected systemctl version '%s'", line)
+	}
+	version, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, fmt.Errorf("unexpected systemctl version '%s'", line)
+	}
+	return version, nil
+}
+
+func (c *SystemdController) Close() error {
+	return nil
+}
+
+func (c *SystemdController) IsEnabled() (bool, error) {
+	props, err := c.show("UnitFileState")
+	if err != nil {
+		return false, err
+	}
+	return props["UnitFileState"] == "enabled", nil
+}
+
+func (c *SystemdController) Enable() error {
+	props, err := c.show("UnitFileState")
+	if err != nil {
+		return err
+	}
+
+	// A masked unit can neither be enabled nor started
+	if props["UnitFileState"] == "masked" {
+		if _, err := c.systemctl("unmask", c.Unit); err != nil {
+			return err
+		}
+	}
+
+	_, err = c.systemctl("enable", c.Unit)
+	return err
+}
+
+func (c *SystemdController) IsRunning() (bool, error) {
+	props, err := c.show("ActiveState")
+	if err != nil {
+		return false, err
+	}
+	return props["ActiveState"] == "active", nil
+}
+
+func (c *SystemdController) Start() error {
+	_, err := c.systemctl("start", "--no-block", c.Unit)
+	return err
+}
+
+func (c *SystemdController) Stop() error {
+	_, err := c.systemctl("stop", "--no-block", c.Unit)
+	return err
+}
+
+func (c *SystemdController) Config() (ServiceConfig, error) {
+	props, err := c.show("Description", "ExecStart", "User", "Requires", "UnitFileState")
+	if err != nil {
+		return ServiceConfig{}, err
+	}
+
+	var startType StartType
+	switch props["UnitFileState"] {
+	case "enabled":
+		startType = StartAutomatic
+	case "masked":
+		startType = StartDisabled
+	default:
+		startType = StartManual
+	}
+
+	return ServiceConfig{
+		DisplayName:      c.Unit,
+		Description:      props["Description"],
+		BinaryPathName:   execStartCommand(props["ExecStart"]),
+		ServiceStartName: props["User"],
+		Dependencies:     strings.Fields(props["Requires"]),
+		StartType:        startType,
+	}, nil
+}
+
+// Read unit properties with 'systemctl show'
+func (c *SystemdController) show(names ...string) (map[string]string, error) {
+	args := []string{"show", c.Unit}
+	for _, name := range names {
+		args = append(args, "-p", name)
+	}
+	out, err := c.systemctl(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	props := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		key, value, found := strings.Cut(line, "=")
+		if found {
+			props[key] = strings.TrimSpace(value)
+		}
+	}
+	return props, nil
+}
+
+func (c *SystemdController) systemctl(args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("systemctl", args...)
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("'systemctl %s' failed: %s:\n %w",
+			strings.Join(args, " "), strings.TrimSpace(stderr.String()), err)
+	}
+	return string(out), nil
+}
+
+// Command line of an ExecStart property, e.g. "{ path=/bin/warp-svc ; argv[]=/bin/warp-svc ; ... }"
+func execStartCommand(execStart string) string {
+	path := ""
+	for _, field := range strings.Split(strings.Trim(execStart, "{} "), ";") {
+		key, value, found := strings.Cut(strings.TrimSpace(field), "=")
+		if !found {
+			continue
+		}
+		switch key {
+		case "argv[]":
+			return strings.TrimSpace(value)
+		case "path":
+			path = strings.TrimSpace(value)
+		}
+	}
+	if path != "" {
+		return path
+	}
+	return execStart
+}
+
+// Name of the drop-in restoring the known-good unit config. systemd applies the
+// drop-ins in lexical order, so the name sorts after the usual ones to win.
+const baselineDropInName = "zzzz-ezforce-baseline.conf"
+
+// Name of the baseline drop-in written by earlier versions
+const oldBaselineDropInName = "ezforce-baseline.conf"
+
+// Restore the unit config through a drop-in overriding the drifted settings.
+// Dependencies can only be added by a drop-in, never removed. Fails when a
+// foreign drop-in sorting after it could override it again.
+func (c *SystemdController) UpdateConfig(cfg ServiceConfig) error {
+	var b strings.Builder
+	b.WriteString("# Managed by ezForce, changes are overwritten\n")
+	b.WriteString("[Unit]\n")
+	fmt.Fprintf(&b, "Description=%s\n", cfg.Description)
+	for _, dependency := range cfg.Dependencies {
+		fmt.Fprintf(&b, "Requires=%s\n", dependency)
+	}
+	b.WriteString("\n[Service]\n")
+	b.WriteString("ExecStart=\n")
+	fmt.Fprintf(&b, "ExecStart=%s\n", cfg.BinaryPathName)
+	fmt.Fprintf(&b, "User=%s\n", cfg.ServiceStartName)
+
+	dir := filepath.Join(systemdUnitDir, c.Unit+".d")
+	path := filepath.Join(dir, baselineDropInName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create drop-in directory:\n %w", err)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write drop-in '%s':\n %w", path, err)
+	}
+	os.Remove(filepath.Join(dir, oldBaselineDropInName))
+	if _, err := c.systemctl("daemon-reload"); err != nil {
+		return err
+	}
+
+	var err error
+	switch cfg.StartType {
+	case StartAutomatic:
+		err = c.Enable()
+	case StartManual:
+		_, err = c.systemctl("disable", c.Unit)
+	}
+	if err != nil {
+		return err
+	}
+
+	props, err := c.show("DropInPaths")
+	if err != nil {
+		return err
+	}
+	if later := dropInsAfter(strings.Fields(props["DropInPaths"]), baselineDropInName); len(later) > 0 {
+		return fmt.Errorf("drop-ins applied after '%s' can override the restored config: %s",
+			path, strings.Join(later, ", "))
+	}
+	return nil
+}
+
+// Drop-ins of the unit applied after the named one, which systemd orders by file name
+func dropInsAfter(paths []string, name string) []string {
+	var later []string
+	for _, path := range paths {
+		if filepath.Ext(path) == ".conf" && filepath.Base(path) > name {
+			later = append(later, path)
+		}
+	}
+	return later
+}
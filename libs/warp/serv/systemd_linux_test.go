@@ -0,0 +1,19 @@
+package serv
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDropInsAfter(t *testing.T) {
+	paths := []string{
+		"/etc/systemd/system/warp-svc.service.d/ezforce-recovery.conf",
+		"/usr/lib/systemd/system/warp-svc.service.d/10-vendor.conf",
+		"/etc/systemd/system/warp-svc.service.d/" + baselineDropInName,
+		"/run/systemd/system/warp-svc.service.d/zzzz-override.conf",
+	}
+	later := dropInsAfter(paths, baselineDropInName)
+	if !slices.Equal(later, paths[3:]) {
+		t.Errorf("dropInsAfter() = %v, want %v", later, paths[3:])
+	}
+}
@@ -0,0 +1,40 @@
+package serv
+
+import (
+	"context"
+	"time"
+)
+
+// State of a service as reported by the service manager
+type ServiceState string
+
+const (
+	StateStopped         ServiceState = "Stopped"
+	StateStartPending    ServiceState = "StartPending"
+	StateStopPending     ServiceState = "StopPending"
+	StateRunning         ServiceState = "Running"
+	StateContinuePending ServiceState = "ContinuePending"
+	StatePausePending    ServiceState = "PausePending"
+	StatePaused          ServiceState = "Paused"
+	StateUnknown         ServiceState = "Unknown"
+)
+
+// Transition of a service from one state to another
+type StateChange struct {
+	From ServiceState
+	To   ServiceState
+	Time time.Time
+}
+
+// Deliver the state transitions of the Warp service as they happen, until ctx is done
+func (s *WarpServ) Watch(ctx context.Context) (<-chan StateChange, error) {
+	return s.Ctrl.Watch(ctx)
+}
+
+// Send a change without blocking the watcher when the consumer is behind
+func sendChange(changes chan<- StateChange, from ServiceState, to ServiceState) {
+	select {
+	case changes <- StateChange{From: from, To: to, Time: time.Now()}:
+	default:
+	}
+}
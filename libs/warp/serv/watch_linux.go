@@ -0,0 +1,139 @@
+package serv
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Safety net for signals lost while the D-Bus connection is down, and the polling
+// interval when it cannot be set up
+const watchFallbackInterval = 5 * time.Second
+
+// Watch the unit through the systemd D-Bus PropertiesChanged signals. systemd only
+// sends them while a client is subscribed, so the watcher subscribes on its connection.
+func (c *SystemdController) Watch(ctx context.Context) (<-chan StateChange, error) {
+	last, err := c.state()
+	if err != nil {
+		return nil, err
+	}
+
+	signals := make(chan struct{}, 1)
+	err = c.subscribe(ctx, signals)
+	if err != nil {
+		log.Warn().Msgf("Could not subscribe to D-Bus signals of '%s', polling its state instead:\n %v", c.Unit, err)
+	}
+
+	changes := make(chan StateChange, 16)
+	go func() {
+		defer close(changes)
+		ticker := time.NewTicker(watchFallbackInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+			case <-ticker.C:
+			}
+
+			state, err := c.state()
+			if err != nil {
+				log.Error().Msgf("Could not get state of '%s':\n %v", c.Unit, err)
+				continue
+			}
+			if state != last {
+				sendChange(changes, last, state)
+				last = state
+			}
+		}
+	}()
+
+	return changes, nil
+}
+
+// Forward the PropertiesChanged signals of the unit to signals until ctx is done
+func (c *SystemdController) subscribe(ctx context.Context, signals chan<- struct{}) error {
+	conn, err := dbus.ConnectSystemBus(dbus.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	err = conn.AddMatchSignalContext(ctx,
+		dbus.WithMatchSender("org.freedesktop.systemd1"),
+		dbus.WithMatchObjectPath(dbus.ObjectPath(unitObjectPath(c.Unit))),
+		dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+		dbus.WithMatchMember("PropertiesChanged"))
+	if err == nil {
+		// Lasts as long as the connection, systemd drops it when the client goes away
+		err = conn.Object("org.freedesktop.systemd1", "/org/freedesktop/systemd1").
+			CallWithContext(ctx, "org.freedesktop.systemd1.Manager.Subscribe", 0).Err
+	}
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	received := make(chan *dbus.Signal, 16)
+	conn.Signal(received)
+	go func() {
+		defer conn.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-received:
+				if !ok {
+					log.Warn().Msgf("D-Bus connection watching '%s' closed, polling its state instead", c.Unit)
+					return
+				}
+				select {
+				case signals <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (c *SystemdController) state() (ServiceState, error) {
+	props, err := c.show("ActiveState")
+	if err != nil {
+		return StateUnknown, err
+	}
+
+	switch props["ActiveState"] {
+	case "active", "reloading":
+		return StateRunning, nil
+	case "activating":
+		return StateStartPending, nil
+	case "deactivating":
+		return StateStopPending, nil
+	case "inactive", "failed":
+		return StateStopped, nil
+	default:
+		return StateUnknown, nil
+	}
+}
+
+// D-Bus object path of a unit, e.g. "/org/freedesktop/systemd1/unit/warp_2dsvc_2eservice"
+func unitObjectPath(unit string) string {
+	var b strings.Builder
+	b.WriteString("/org/freedesktop/systemd1/unit/")
+	for i := 0; i < len(unit); i++ {
+		ch := unit[i]
+		isAlpha := (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+		isDigit := ch >= '0' && ch <= '9'
+		if isAlpha || (isDigit && i > 0) {
+			b.WriteByte(ch)
+		} else {
+			fmt.Fprintf(&b, "_%02x", ch)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,115 @@
+package serv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+
+	"golang.org/x/sys/windows"
+)
+
+// Service states to be notified about
+const notifyMask = windows.SERVICE_NOTIFY_STOPPED | windows.SERVICE_NOTIFY_START_PENDING |
+	windows.SERVICE_NOTIFY_STOP_PENDING | windows.SERVICE_NOTIFY_RUNNING |
+	windows.SERVICE_NOTIFY_CONTINUE_PENDING | windows.SERVICE_NOTIFY_PAUSE_PENDING |
+	windows.SERVICE_NOTIFY_PAUSED
+
+// How often the alertable wait wakes up to check for cancellation, in milliseconds
+const notifyPollMillis = 250
+
+// Does nothing, the notification is read from the SERVICE_NOTIFY once the APC ran.
+// Created once as the number of callbacks a process can create is limited.
+var notifyCallback = windows.NewCallback(func(notifier uintptr) uintptr {
+	return 0
+})
+
+// Watch the service through NotifyServiceStatusChange
+func (c *SCMController) Watch(ctx context.Context) (<-chan StateChange, error) {
+	// Own handle, closing it cancels the pending notification
+	name, err := windows.UTF16PtrFromString(c.Service.Name)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := windows.OpenService(c.ServMgr.Handle, name, windows.SERVICE_QUERY_STATUS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open service '%s' for notifications:\n %w", c.Service.Name, err)
+	}
+
+	initial, err := c.Service.Query()
+	if err != nil {
+		windows.CloseServiceHandle(handle)
+		return nil, fmt.Errorf("failed to get service status:\n %w", err)
+	}
+
+	changes := make(chan StateChange, 16)
+	go func() {
+		defer close(changes)
+		defer windows.CloseServiceHandle(handle)
+
+		// The notification is delivered as an APC to the registering thread
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		last := scmState(uint32(initial.State))
+		notify := &windows.SERVICE_NOTIFY{
+			Version:        windows.SERVICE_NOTIFY_STATUS_CHANGE,
+			NotifyCallback: notifyCallback,
+		}
+
+		for {
+			err := windows.NotifyServiceStatusChange(handle, notifyMask, notify)
+			if err != nil {
+				if errors.Is(err, windows.ERROR_SERVICE_NOTIFY_CLIENT_LAGGING) {
+					log.Warn().Msgf("Notifications of service '%s' lagged behind", c.Service.Name)
+				} else {
+					log.Error().Msgf("Could not watch service '%s':\n %v", c.Service.Name, err)
+				}
+				return
+			}
+
+			// Sleep alertable so the APC can run, waking up to check for cancellation
+			for windows.SleepEx(notifyPollMillis, true) != windows.WAIT_IO_COMPLETION {
+				if ctx.Err() != nil {
+					return
+				}
+			}
+			if notify.NotificationStatus != uint32(windows.ERROR_SUCCESS) {
+				log.Error().Msgf("Notification of service '%s' failed with status %d", c.Service.Name, notify.NotificationStatus)
+				return
+			}
+
+			state := scmState(notify.ServiceStatus.CurrentState)
+			if state != last {
+				sendChange(changes, last, state)
+				last = state
+			}
+			if ctx.Err() != nil {
+				return
+			}
+		}
+	}()
+
+	return changes, nil
+}
+
+func scmState(state uint32) ServiceState {
+	switch state {
+	case windows.SERVICE_STOPPED:
+		return StateStopped
+	case windows.SERVICE_START_PENDING:
+		return StateStartPending
+	case windows.SERVICE_STOP_PENDING:
+		return StateStopPending
+	case windows.SERVICE_RUNNING:
+		return StateRunning
+	case windows.SERVICE_CONTINUE_PENDING:
+		return StateContinuePending
+	case windows.SERVICE_PAUSE_PENDING:
+		return StatePausePending
+	case windows.SERVICE_PAUSED:
+		return StatePaused
+	default:
+		return StateUnknown
+	}
+}
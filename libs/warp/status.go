@@ -0,0 +1,153 @@
+package warp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type ConnectionState string
+
+const (
+	StateConnected           ConnectionState = "Connected"
+	StateConnecting          ConnectionState = "Connecting"
+	StateDisconnected        ConnectionState = "Disconnected"
+	StateDisconnecting       ConnectionState = "Disconnecting"
+	StateUnable              ConnectionState = "Unable"
+	StateRegistrationMissing ConnectionState = "RegistrationMissing"
+	StateUnknown             ConnectionState = "Unknown"
+)
+
+// Parsed output of 'warp-cli status'
+type ConnectionStatus struct {
+	State ConnectionState
+	// State exactly as printed by warp-cli
+	RawState string
+	Reason   string
+	// Network health, e.g. "healthy"
+	Network string
+	Mode    string
+}
+
+func (s *ConnectionStatus) IsConnected() bool {
+	return s != nil && s.State == StateConnected
+}
+
+func (s *ConnectionStatus) String() string {
+	str := string(s.State)
+	if s.Reason != "" {
+		str += " (" + s.Reason + ")"
+	}
+	if s.Network != "" {
+		str += ", network " + s.Network
+	}
+	if s.Mode != "" {
+		str += ", mode " + s.Mode
+	}
+	return str
+}
+
+// Parse the plain text or JSON output of 'warp-cli status'
+func ParseStatus(out string) (*ConnectionStatus, error) {
+	out = strings.TrimSpace(out)
+	if out == "" {
+		return nil, errors.New("empty Warp status output")
+	}
+	if strings.HasPrefix(out, "{") {
+		return parseJSONStatus(out)
+	}
+	return parseTextStatus(out)
+}
+
+// Plain output, e.g.:
+//
+//	Status update: Disconnected
+//	Reason: Manual Disconnection
+func parseTextStatus(out string) (*ConnectionStatus, error) {
+	status := &ConnectionStatus{}
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			// Older clients print a bare "Registration Missing" line
+			if strings.EqualFold(line, "Registration Missing") {
+				status.RawState = line
+			}
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "status update", "status":
+			status.RawState = value
+		case "reason":
+			status.Reason = value
+		case "network", "network health":
+			status.Network = value
+		case "mode":
+			status.Mode = value
+		case "registration missing due to":
+			status.RawState = "Registration Missing"
+			status.Reason = value
+		}
+	}
+
+	if status.RawState == "" {
+		return nil, fmt.Errorf("could not find connection state in Warp status output: %q", out)
+	}
+	status.State = parseState(status.RawState)
+	return status, nil
+}
+
+// JSON output of 'warp-cli --json status'
+func parseJSONStatus(out string) (*ConnectionStatus, error) {
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(out), &fields); err != nil {
+		return nil, fmt.Errorf("could not parse Warp JSON status:\n %w", err)
+	}
+
+	status := &ConnectionStatus{}
+	for key, value := range fields {
+		str, ok := value.(string)
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(key) {
+		case "status", "state", "status_update":
+			status.RawState = str
+		case "reason":
+			status.Reason = str
+		case "network", "network_health":
+			status.Network = str
+		case "mode":
+			status.Mode = str
+		}
+	}
+
+	if status.RawState == "" {
+		return nil, fmt.Errorf("could not find connection state in Warp JSON status: %q", out)
+	}
+	status.State = parseState(status.RawState)
+	return status, nil
+}
+
+func parseState(raw string) ConnectionState {
+	normalized := strings.ToLower(strings.Join(strings.Fields(raw), ""))
+	switch {
+	case normalized == "connected":
+		return StateConnected
+	case normalized == "connecting":
+		return StateConnecting
+	case normalized == "disconnected":
+		return StateDisconnected
+	case normalized == "disconnecting":
+		return StateDisconnecting
+	case strings.HasPrefix(normalized, "unable"):
+		return StateUnable
+	case strings.HasPrefix(normalized, "registrationmissing"):
+		return StateRegistrationMissing
+	default:
+		return StateUnknown
+	}
+}
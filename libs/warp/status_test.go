@@ -0,0 +1,63 @@
+package warp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseStatus(t *testing.T) {
+	tests := []struct {
+		file   string
+		state  ConnectionState
+		reason string
+	}{
+		{"connected.txt", StateConnected, ""},
+		{"connected.json", StateConnected, ""},
+		{"connecting.txt", StateConnecting, "Checking for a route to the DNS endpoint"},
+		{"connecting.json", StateConnecting, "Checking for a route to the DNS endpoint"},
+		{"disconnected.txt", StateDisconnected, "Manual Disconnection"},
+		{"disconnected.json", StateDisconnected, "Manual Disconnection"},
+		{"disconnected_trusted.txt", StateDisconnected, "Connected to a trusted network"},
+		{"disconnected_trusted.json", StateDisconnected, "Connected to a trusted network"},
+		{"disconnecting.txt", StateDisconnecting, ""},
+		{"disconnecting.json", StateDisconnecting, ""},
+		{"unable.txt", StateUnable, "No Network"},
+		{"unable.json", StateUnable, "No Network"},
+		{"registration_missing.txt", StateRegistrationMissing, ""},
+		{"registration_missing_bare.txt", StateRegistrationMissing, ""},
+		{"registration_missing_due_to.txt", StateRegistrationMissing, "Daemon Startup"},
+		{"registration_missing.json", StateRegistrationMissing, "Daemon Startup"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			out, err := os.ReadFile(filepath.Join("testdata", "status", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			status, err := ParseStatus(string(out))
+			if err != nil {
+				t.Fatalf("ParseStatus() error = %v", err)
+			}
+			if status.State != tt.state {
+				t.Errorf("State = %q, want %q", status.State, tt.state)
+			}
+			if status.Reason != tt.reason {
+				t.Errorf("Reason = %q, want %q", status.Reason, tt.reason)
+			}
+			if status.IsConnected() != (tt.state == StateConnected) {
+				t.Errorf("IsConnected() = %v for state %q", status.IsConnected(), status.State)
+			}
+		})
+	}
+}
+
+func TestParseStatusRejectsOutputWithoutState(t *testing.T) {
+	for _, out := range []string{"", "   \n", "Network: healthy", `{"network":"healthy"}`} {
+		if _, err := ParseStatus(out); err == nil {
+			t.Errorf("ParseStatus(%q) succeeded, want error", out)
+		}
+	}
+}
@@ -1,35 +1,40 @@
 package warp
 
 import (
+	"context"
 	"fmt"
-	"os/exec"
-	"strings"
+	"path/filepath"
 	"time"
 
 	"github.com/ezydark/ezforce/app/config"
+	"github.com/ezydark/ezforce/libs/events"
+	"github.com/ezydark/ezforce/libs/logger"
+	"github.com/ezydark/ezforce/libs/wait"
 	"github.com/ezydark/ezforce/libs/warp/serv"
 	"github.com/ezydark/ezforce/libs/win"
-	"github.com/rs/zerolog/log"
 )
 
+// Logger of the package, tagged with its component
+var log = logger.Component("warp")
+
 var Serv *serv.WarpServ
 
 // Check if Warp executables are installed
 func IsInstalled() (bool, error) {
 	// Check if Warp's folder exists
-	warpDirExists, err := win.Fs.DirExists(config.Warp.FolderPath)
+	warpDirExists, err := win.Fs.DirExists(config.Warp().FolderPath)
 	if err != nil {
 		return false, fmt.Errorf("Could not check if Warp's folder exists:\n %w", err)
 	}
 
 	// Check if Warp GUI executable exists
-	warpGuiExists, err := win.Fs.FileExists(config.Warp.FolderPath + "\\" + config.Warp.GUIExecName)
+	warpGuiExists, err := win.Fs.FileExists(filepath.Join(config.Warp().FolderPath, config.Warp().GUIExecName))
 	if err != nil {
 		return false, fmt.Errorf("Could not check if Warp GUI exists:\n %w", err)
 	}
 
 	// Check if 'warp-svc.exe' exists
-	warpSvcExists, err := win.Fs.FileExists(config.Warp.FolderPath + "\\" + config.Warp.SvcExecName)
+	warpSvcExists, err := win.Fs.FileExists(filepath.Join(config.Warp().FolderPath, config.Warp().SvcExecName))
 	if err != nil {
 		return false, fmt.Errorf("Could not check if Warp Svc exists:\n %w", err)
 	}
@@ -43,70 +48,72 @@ func IsInstalled() (bool, error) {
 
 // Check if Warp process is connected to the Cloudflare service
 func IsConnected() (bool, error) {
-	cmd := exec.Command("warp-cli", "status")
-	out, err := cmd.Output()
+	status, err := Client.Status()
 	if err != nil {
 		return false, fmt.Errorf("error checking Warp status:\n %w", err)
 	}
+	log.Debug().Msgf("Warp status: %v", status)
 
-	if strings.Contains(string(out), "Connected") {
-		return true, nil
-	} else {
-		return false, nil
-	}
+	return status.IsConnected(), nil
 }
 
 // Connect Warp to the Cloudflare service
-func Connect() error {
-	cmd := exec.Command("warp-cli", "connect")
-	out, err := cmd.Output()
+func Connect(ctx context.Context) error {
+	err := Client.Connect()
 	if err != nil {
 		return fmt.Errorf("error connecting Warp to Cloudflare service:\n %w", err)
 	}
 
-	if strings.Contains(string(out), "Success") {
-		return nil
-	} else {
-		return waitForWarpToConnect(20, 500*time.Millisecond)
-	}
+	return waitForWarpToConnect(ctx)
 }
 
-func EnsureIsConnected() error {
-	isConnected, err := IsConnected()
+func EnsureIsConnected(ctx context.Context) (err error) {
+	ev := events.Begin("warp", events.StepConnected, string(StateConnected))
+	defer func() { ev.End(err) }()
+
+	status, err := Client.Status()
 	if err != nil {
-		return fmt.Errorf("could not check Warp connection state to Cloudflare service:\n %v", err)
+		return fmt.Errorf("could not check Warp connection state to Cloudflare service:\n %w", err)
 	}
-	if !isConnected {
-		err = Connect()
-		if err != nil {
-			return fmt.Errorf("could not connect Warp to the Cloudflare service:\n %v", err)
-		}
+	ev.Observe(string(status.State))
+	if status.IsConnected() {
+		return nil
 	}
-	return nil
-}
 
-func waitForWarpToConnect(maxAttempts int, waitTime time.Duration) error {
-	connected, err := IsConnected()
+	ev.Act(events.ActionConnect)
+	err = Connect(ctx)
 	if err != nil {
-		return fmt.Errorf("could not check Warp connection state to Cloudflare service:\n %v", err)
-	}
-	if connected {
-		return nil
+		return fmt.Errorf("could not connect Warp to the Cloudflare service:\n %w", err)
 	}
+	return nil
+}
 
-	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		log.Debug().Msgf("[%v/%v] Waiting for Warp to connect to Cloudflare service...", attempt, maxAttempts)
-		time.Sleep(waitTime)
+// How long to wait for Warp to connect to the Cloudflare service
+var ConnectWaitPolicy = wait.Policy{
+	MaxAttempts:  20,
+	InitialDelay: 250 * time.Millisecond,
+	MaxDelay:     2 * time.Second,
+	Multiplier:   1.5,
+	Jitter:       0.1,
+	Timeout:      30 * time.Second,
+	OnProgress: func(attempt int, maxAttempts int, next time.Duration) {
+		log.Debug().Msgf("[%v/%v] Waiting %v for Warp to connect to Cloudflare service...",
+			attempt, maxAttempts, next.Round(time.Millisecond))
+	},
+}
 
-		connected, err = IsConnected()
+func waitForWarpToConnect(ctx context.Context) error {
+	err := wait.WaitFor(ctx, func(ctx context.Context) (bool, error) {
+		connected, err := IsConnected()
 		if err != nil {
-			return fmt.Errorf("could not check Warp connection state to Cloudflare service:\n %v", err)
-		}
-		if connected {
-			log.Debug().Msgf("Warp connected to Cloudflare service after %v attempts", attempt)
-			return nil
+			return false, fmt.Errorf("could not check Warp connection state to Cloudflare service:\n %v", err)
 		}
+		return connected, nil
+	}, ConnectWaitPolicy)
+	if err != nil {
+		return fmt.Errorf("could not connect Warp to the Cloudflare service:\n %w", err)
 	}
 
-	return fmt.Errorf("could not connect Warp to the Cloudflare service after %d attempts", maxAttempts)
+	log.Debug().Msg("Warp connected to Cloudflare service")
+	return nil
 }
@@ -0,0 +1,152 @@
+package warp
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ezydark/ezforce/libs/wait"
+)
+
+// Use the fake as the package client and wait without sleeping
+func useFakeClient(t *testing.T) (*FakeClient, *wait.FakeClock) {
+	t.Helper()
+	fake := NewFakeClient()
+	clock := wait.NewFakeClock()
+
+	client, policy := Client, ConnectWaitPolicy
+	t.Cleanup(func() { Client, ConnectWaitPolicy = client, policy })
+	Client = fake
+	ConnectWaitPolicy.Clock = clock
+	ConnectWaitPolicy.Jitter = 0
+	return fake, clock
+}
+
+func count(calls []string, name string) int {
+	n := 0
+	for _, call := range calls {
+		if call == name {
+			n++
+		}
+	}
+	return n
+}
+
+func TestEnsureIsConnectedAlreadyConnected(t *testing.T) {
+	fake, clock := useFakeClient(t)
+	fake.Connected = true
+
+	if err := EnsureIsConnected(context.Background()); err != nil {
+		t.Fatalf("EnsureIsConnected(context.Background()) error = %v", err)
+	}
+	if !slices.Equal(fake.Calls, []string{"Status"}) {
+		t.Errorf("Calls = %v, want only the status check", fake.Calls)
+	}
+	if len(clock.Waits) != 0 {
+		t.Errorf("waited %v while already connected", clock.Waits)
+	}
+}
+
+func TestEnsureIsConnectedWaitsForConnectAfter(t *testing.T) {
+	fake, clock := useFakeClient(t)
+	fake.ConnectAfter = 3
+
+	if err := EnsureIsConnected(context.Background()); err != nil {
+		t.Fatalf("EnsureIsConnected(context.Background()) error = %v", err)
+	}
+	if !fake.Connected {
+		t.Error("fake not connected after EnsureIsConnected")
+	}
+	if n := count(fake.Calls, "Connect"); n != 1 {
+		t.Errorf("Connect called %d times, want 1", n)
+	}
+	// One check before connecting, three while connecting and the connected one
+	if n := count(fake.Calls, "Status"); n != 5 {
+		t.Errorf("Status called %d times, want 5", n)
+	}
+	if len(clock.Waits) != 3 {
+		t.Errorf("waited %d times, want 3", len(clock.Waits))
+	}
+}
+
+func TestWaitForWarpToConnectExhausted(t *testing.T) {
+	fake, _ := useFakeClient(t)
+	ConnectWaitPolicy.MaxAttempts = 4
+	ConnectWaitPolicy.Timeout = 0
+	fake.ConnectAfter = 10
+
+	if err := fake.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	err := waitForWarpToConnect(context.Background())
+	if !errors.Is(err, wait.ErrExhausted) {
+		t.Fatalf("waitForWarpToConnect(context.Background()) error = %v, want %v", err, wait.ErrExhausted)
+	}
+	if n := count(fake.Calls, "Status"); n != 4 {
+		t.Errorf("Status called %d times, want 4", n)
+	}
+}
+
+func TestWaitForWarpToConnectTimeout(t *testing.T) {
+	fake, clock := useFakeClient(t)
+	ConnectWaitPolicy.MaxAttempts = 0
+	fake.ConnectAfter = 1000
+
+	if err := fake.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	err := waitForWarpToConnect(context.Background())
+	if !errors.Is(err, wait.ErrTimeout) {
+		t.Fatalf("waitForWarpToConnect(context.Background()) error = %v, want %v", err, wait.ErrTimeout)
+	}
+	if elapsed := clock.Now().Sub(time.Unix(0, 0)); elapsed != ConnectWaitPolicy.Timeout {
+		t.Errorf("waited %v, want the %v timeout", elapsed, ConnectWaitPolicy.Timeout)
+	}
+}
+
+func TestEnsureIsConnectedStatusError(t *testing.T) {
+	fake, _ := useFakeClient(t)
+	fake.Err = errors.New("daemon not running")
+
+	err := EnsureIsConnected(context.Background())
+	if !errors.Is(err, fake.Err) {
+		t.Fatalf("EnsureIsConnected(context.Background()) error = %v, want %v", err, fake.Err)
+	}
+	if count(fake.Calls, "Connect") != 0 {
+		t.Error("Connect called although the status check failed")
+	}
+}
+
+func TestWaitForWarpToConnectStopsOnError(t *testing.T) {
+	fake, clock := useFakeClient(t)
+	fake.ConnectAfter = 5
+	if err := fake.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	fake.Err = errors.New("daemon not running")
+
+	if err := waitForWarpToConnect(context.Background()); err == nil {
+		t.Fatal("waitForWarpToConnect(context.Background()) succeeded while the client fails")
+	}
+	if n := count(fake.Calls, "Status"); n != 1 {
+		t.Errorf("Status called %d times, want 1", n)
+	}
+	if len(clock.Waits) != 0 {
+		t.Errorf("waited %v after an error", clock.Waits)
+	}
+}
+
+func TestEnsureIsConnectedStopsWithContext(t *testing.T) {
+	fake, clock := useFakeClient(t)
+	clock.Stuck = true
+	fake.ConnectAfter = 1000
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := EnsureIsConnected(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("EnsureIsConnected() error = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,30 @@
+//go:build !windows
+
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+type Admin struct{}
+
+func (a *Admin) EnsureSelfAdmin() error {
+	if !a.IsSelfAdmin() {
+		if err := a.RunSelfAsAdmin(); err != nil {
+			return fmt.Errorf("Could not run self as admin:\n %w", err)
+		}
+
+		log.Fatal().Msg("Stopping this instance of program... Starting as admin instead\n")
+	}
+	return nil
+}
+
+func (a *Admin) IsSelfAdmin() bool {
+	return os.Geteuid() == 0
+}
+
+func (a *Admin) RunSelfAsAdmin() error {
+	return errors.New("elevating is not supported on this platform, run again as root (e.g. with sudo)")
+}
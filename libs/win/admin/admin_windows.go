@@ -6,7 +6,6 @@ import (
 	"strings"
 	"syscall"
 
-	"github.com/rs/zerolog/log"
 	"golang.org/x/sys/windows"
 )
 
@@ -0,0 +1,6 @@
+package admin
+
+import "github.com/ezydark/ezforce/libs/logger"
+
+// Logger of the package, tagged with its component
+var log = logger.Component("win/admin")
@@ -1,13 +1,17 @@
 package processutil
 
 import (
+	"errors"
 	"fmt"
 	"os/exec"
 
-	"github.com/rs/zerolog/log"
+	"github.com/ezydark/ezforce/libs/logger"
 	"github.com/shirou/gopsutil/process"
 )
 
+// Logger of the package, tagged with its component
+var log = logger.Component("processutil")
+
 type Process struct{}
 
 func (p *Process) IsProcessRunningByName(name string) (bool, error) {
@@ -28,7 +32,8 @@ func (p *Process) IsProcessRunningByName(name string) (bool, error) {
 	return false, nil
 }
 
-func (p *Process) StartProcess(path string, args ...string) error {
+// Start a detached process, returning its PID
+func (p *Process) StartProcess(path string, args ...string) (int32, error) {
 	cmd := exec.Command(path, args...)
 
 	cmd.Stdout = nil
@@ -36,7 +41,7 @@ func (p *Process) StartProcess(path string, args ...string) error {
 	cmd.Stdin = nil
 
 	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start process:\n %w", err)
+		return 0, fmt.Errorf("failed to start process:\n %w", err)
 	}
 
 	processID := cmd.Process.Pid
@@ -44,8 +49,55 @@ func (p *Process) StartProcess(path string, args ...string) error {
 	log.Info().Msgf("Started process '%s' with PID '%d'", path, processID)
 
 	if err := cmd.Process.Release(); err != nil {
-		return fmt.Errorf("failed to release process:\n %w", err)
+		return 0, fmt.Errorf("failed to release process:\n %w", err)
+	}
+
+	return int32(processID), nil
+}
+
+// Process identified by its PID and creation time, so that another process
+// reusing the PID after it exited is not mistaken for it
+type TrackedProcess struct {
+	PID        int32
+	CreateTime int64
+}
+
+// Start tracking the liveness of the process with the given PID
+func (p *Process) Track(pid int32) (*TrackedProcess, error) {
+	proc, err := process.NewProcess(pid)
+	if err != nil {
+		return nil, fmt.Errorf("could not find process with PID '%d':\n %w", pid, err)
+	}
+	createTime, err := proc.CreateTime()
+	if err != nil {
+		return nil, fmt.Errorf("could not get creation time of process with PID '%d':\n %w", pid, err)
+	}
+	return &TrackedProcess{PID: pid, CreateTime: createTime}, nil
+}
+
+// Check if the tracked process is still running
+func (t *TrackedProcess) IsAlive() (bool, error) {
+	proc, err := process.NewProcess(t.PID)
+	if errors.Is(err, process.ErrorProcessNotRunning) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("could not check process with PID '%d':\n %w", t.PID, err)
 	}
 
-	return nil
+	// The PID was reused by another process
+	createTime, err := proc.CreateTime()
+	if err != nil {
+		return false, fmt.Errorf("could not get creation time of process with PID '%d':\n %w", t.PID, err)
+	}
+	if createTime != t.CreateTime {
+		return false, nil
+	}
+
+	// An exited child nobody waited for yet, not available on every platform
+	status, err := proc.Status()
+	if err == nil && status == "Z" {
+		return false, nil
+	}
+	return true, nil
 }
@@ -1,18 +1,24 @@
+//go:build windows
+
 package serv
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
 	"os"
-	"path/filepath"
-	"time"
 
+	"github.com/ezydark/ezforce/app/config"
+	"github.com/ezydark/ezforce/app/enforce"
+	"github.com/ezydark/ezforce/libs/audit"
+	"github.com/ezydark/ezforce/libs/warp"
+	"github.com/rs/zerolog/log"
 	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/debug"
 	"golang.org/x/sys/windows/svc/eventlog"
 	"golang.org/x/sys/windows/svc/mgr"
 )
 
-const serviceName = "ezForce"
 const serviceDisplayName = "ezForce"
 const serviceDescription = "Enforcer preventing social media addiction from affecting productivity"
 
@@ -22,55 +28,67 @@ type ezForceServ struct{}
 func (m *ezForceServ) Execute(args []string, r <-chan svc.ChangeRequest, changes chan<- svc.Status) (ssec bool, errno uint32) {
 	changes <- svc.Status{State: svc.StartPending}
 
-	// Set up logging
-	logFile, err := os.OpenFile(filepath.Join(filepath.Dir(os.Args[0]), "service.log"),
-		os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err == nil {
-		defer logFile.Close()
-		log.SetOutput(logFile)
-	}
-
-	log.Println("Service started")
+	log.Info().Msg("Service started")
 
 	// Service is now running
 	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
 
-	// Main service loop
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
+	// Enforce until the service is stopped
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		enforce.Loop(ctx)
+	}()
 
 loop:
-	for {
-		select {
-		case <-ticker.C:
-			log.Println("Service is running - heartbeat")
-			// Your service work here
-
-		case c := <-r:
-			switch c.Cmd {
-			case svc.Interrogate:
-				changes <- c.CurrentStatus
-			case svc.Stop, svc.Shutdown:
-				log.Println("Service stopping")
-				changes <- svc.Status{State: svc.StopPending}
-				break loop
-			default:
-				log.Printf("Unexpected control request: %d", c)
-			}
+	for c := range r {
+		switch c.Cmd {
+		case svc.Interrogate:
+			changes <- c.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			log.Info().Msg("Service stopping")
+			changes <- svc.Status{State: svc.StopPending}
+			cancel()
+			<-done
+			break loop
+		default:
+			log.Warn().Msgf("Unexpected control request: %d", c.Cmd)
 		}
 	}
 
 	return false, 0
 }
 
-func runService() {
-	err := svc.Run(serviceName, &ezForceServ{})
+// Returned when the ezForce service is not installed
+var ErrNotInstalled = errors.New("service is not installed")
+
+// Check if the process was started by the service control manager
+func IsService() (bool, error) {
+	return svc.IsWindowsService()
+}
+
+// Run as the ezForce service, to be called when started by the service control manager
+func Run() error {
+	err := svc.Run(config.App().ServiceName, &ezForceServ{})
+	if err != nil {
+		return fmt.Errorf("service failed: %v", err)
+	}
+	return nil
+}
+
+// Run the service logic on the console, as if started by the service control manager
+func Debug() error {
+	err := debug.Run(config.App().ServiceName, &ezForceServ{})
 	if err != nil {
-		log.Fatalf("Service failed: %v", err)
+		return fmt.Errorf("service failed: %v", err)
 	}
+	return nil
 }
 
-func installService() error {
+// Install ezForce as an automatically starting service running 'ezforce run'
+func Install() error {
 	exePath, err := os.Executable()
 	if err != nil {
 		return fmt.Errorf("could not get executable path: %v", err)
@@ -82,12 +100,24 @@ func installService() error {
 	}
 	defer m.Disconnect()
 
+	serviceName := config.App().ServiceName
 	s, err := m.OpenService(serviceName)
 	if err == nil {
 		s.Close()
 		return fmt.Errorf("service %s already exists", serviceName)
 	}
 
+	// The install path holds the baseline, set up before registering the service
+	// so that a failure leaves nothing behind that would fail a retry
+	err = os.MkdirAll(config.App().InstallPath, 0755)
+	if err != nil {
+		return fmt.Errorf("could not create install directory: %v", err)
+	}
+	err = prepareInstall()
+	if err != nil {
+		return err
+	}
+
 	s, err = m.CreateService(
 		serviceName,
 		exePath,
@@ -95,7 +125,8 @@ func installService() error {
 			DisplayName: serviceDisplayName,
 			Description: serviceDescription,
 			StartType:   mgr.StartAutomatic, // Set to start automatically
-		})
+		},
+		"run")
 	if err != nil {
 		return fmt.Errorf("could not create service: %v", err)
 	}
@@ -111,16 +142,44 @@ func installService() error {
 	return nil
 }
 
-func removeService() error {
+// Snapshot the known-good Warp service config, require signed config files and start the audit log
+func prepareInstall() error {
+	warpServ, err := warp.Serv.Init()
+	if err != nil {
+		return fmt.Errorf("could not open Warp service: %v", err)
+	}
+	defer warpServ.Close()
+	_, err = warpServ.SaveBaseline()
+	if err != nil {
+		return fmt.Errorf("could not save Warp service baseline: %v", err)
+	}
+
+	// Removing the pinned public key must not turn the signature checks off
+	err = config.RequireSigning()
+	if err != nil {
+		return fmt.Errorf("could not require signed config files: %v", err)
+	}
+
+	// From now on a missing audit log was deleted
+	err = audit.RecordInstall()
+	if err != nil {
+		return fmt.Errorf("could not record the install in the audit log: %v", err)
+	}
+	return nil
+}
+
+// Remove the ezForce service and its event log source
+func Remove() error {
 	m, err := mgr.Connect()
 	if err != nil {
 		return fmt.Errorf("could not connect to service manager: %v", err)
 	}
 	defer m.Disconnect()
 
+	serviceName := config.App().ServiceName
 	s, err := m.OpenService(serviceName)
 	if err != nil {
-		return fmt.Errorf("service %s is not installed", serviceName)
+		return fmt.Errorf("service %s: %w", serviceName, ErrNotInstalled)
 	}
 	defer s.Close()
 
@@ -137,14 +196,14 @@ func removeService() error {
 	return nil
 }
 
-func startService() error {
+func Start() error {
 	m, err := mgr.Connect()
 	if err != nil {
 		return fmt.Errorf("could not connect to service manager: %v", err)
 	}
 	defer m.Disconnect()
 
-	s, err := m.OpenService(serviceName)
+	s, err := m.OpenService(config.App().ServiceName)
 	if err != nil {
 		return fmt.Errorf("could not open service: %v", err)
 	}
@@ -158,14 +217,14 @@ func startService() error {
 	return nil
 }
 
-func stopService() error {
+func Stop() error {
 	m, err := mgr.Connect()
 	if err != nil {
 		return fmt.Errorf("could not connect to service manager: %v", err)
 	}
 	defer m.Disconnect()
 
-	s, err := m.OpenService(serviceName)
+	s, err := m.OpenService(config.App().ServiceName)
 	if err != nil {
 		return fmt.Errorf("could not open service: %v", err)
 	}
@@ -179,68 +238,41 @@ func stopService() error {
 	return nil
 }
 
-func usage() {
-	fmt.Printf("Usage:\n")
-	fmt.Printf("  %s install   - Install the service\n", os.Args[0])
-	fmt.Printf("  %s remove    - Remove the service\n", os.Args[0])
-	fmt.Printf("  %s start     - Start the service\n", os.Args[0])
-	fmt.Printf("  %s stop      - Stop the service\n", os.Args[0])
-	fmt.Printf("  %s debug     - Run service in debug mode\n", os.Args[0])
-}
+// Current state of the ezForce service, e.g. "Running"
+func Status() (string, error) {
+	m, err := mgr.Connect()
+	if err != nil {
+		return "", fmt.Errorf("could not connect to service manager: %v", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(config.App().ServiceName)
+	if err != nil {
+		return "", fmt.Errorf("service %s: %w", config.App().ServiceName, ErrNotInstalled)
+	}
+	defer s.Close()
 
-// func main() {
-// 	isService, err := svc.IsWindowsService()
-// 	if err != nil {
-// 		log.Fatalf("Failed to determine if we're running as Windows Service: %v", err)
-// 	}
-
-// 	// If no command line arguments and not running as service, run as service
-// 	if isService && len(os.Args) == 1 {
-// 		runService()
-// 		return
-// 	}
-
-// 	// Ensure to run myself as admin
-// 	if err := admin.EnsureAdmin(); err != nil {
-// 		log.Fatalf("Could not ensure if I ran as admin:\n %v", err)
-// 	}
-
-// 	// If running as service or with arguments, handle commands
-// 	if len(os.Args) < 2 {
-// 		usage()
-// 		return
-// 	}
-
-// 	cmd := os.Args[1]
-// 	switch cmd {
-// 	case "install":
-// 		err := installService()
-// 		if err != nil {
-// 			log.Fatalf("Failed to install service: %v", err)
-// 		}
-// 	case "remove":
-// 		err := removeService()
-// 		if err != nil {
-// 			log.Fatalf("Failed to remove service: %v", err)
-// 		}
-// 	case "start":
-// 		err := startService()
-// 		if err != nil {
-// 			log.Fatalf("Failed to start service: %v", err)
-// 		}
-// 	case "stop":
-// 		err := stopService()
-// 		if err != nil {
-// 			log.Fatalf("Failed to stop service: %v", err)
-// 		}
-// 	case "debug":
-// 		// Run service in debug mode
-// 		debug.Run(serviceName, &myService{})
-// 		return
-// 	default:
-// 		usage()
-// 		return
-// 	}
-
-// 	fmt.Printf("Command '%s' completed successfully\n", cmd)
-// }
+	status, err := s.Query()
+	if err != nil {
+		return "", fmt.Errorf("could not query service: %v", err)
+	}
+
+	switch status.State {
+	case svc.Stopped:
+		return "Stopped", nil
+	case svc.StartPending:
+		return "StartPending", nil
+	case svc.StopPending:
+		return "StopPending", nil
+	case svc.Running:
+		return "Running", nil
+	case svc.ContinuePending:
+		return "ContinuePending", nil
+	case svc.PausePending:
+		return "PausePending", nil
+	case svc.Paused:
+		return "Paused", nil
+	default:
+		return "Unknown", nil
+	}
+}
@@ -1,11 +1,17 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"os"
 
+	"github.com/ezydark/ezforce/app/config"
+	"github.com/ezydark/ezforce/app/enforce"
+	"github.com/ezydark/ezforce/libs/audit"
+	"github.com/ezydark/ezforce/libs/events"
 	"github.com/ezydark/ezforce/libs/logger"
 	"github.com/ezydark/ezforce/libs/util"
-	"github.com/ezydark/ezforce/libs/warp"
 	"github.com/ezydark/ezforce/libs/win"
 	"github.com/fatih/color"
 	"github.com/rs/zerolog/log"
@@ -19,53 +25,97 @@ func main() {
 		fmt.Println(fatal_tag, "Could not initialize custom logger:", err)
 		return
 	}
-	log.Info().Msg(color.New(color.Bold).Sprintf("WarpEnforcer starting..."))
-	util.WaitForInput()
 
-	// Ensure to run myself as admin
-	err = win.Admin.EnsureSelfAdmin()
+	// Load config layers over the default configs
+	config.RegisterFlags(flag.CommandLine)
+	nonInteractive := flag.Bool("non-interactive", false,
+		"Never prompt, implied when stdin is not a terminal or when running as a service")
+	flag.Usage = usage
+	flag.Parse()
+	err = config.Load()
 	if err != nil {
-		log.Fatal().Msgf("Could not ensure if I ran as admin:\n %v", err)
+		log.Fatal().Msgf("Could not load config:\n %v", err)
 	}
 
-	// Check if Warp service is installed
-	warpInstalled, err := warp.IsInstalled()
+	err = logger.Configure(config.App().LogLevel, config.App().LogFormat)
 	if err != nil {
-		log.Fatal().Msgf("Could not ensure Warp is installed:\n %v", err)
-	}
-	if !warpInstalled {
-		log.Fatal().Msg("Warp is not properly installed! Install it using package manager like 'winget' or other.")
-	} else {
-		log.Info().Msg("Warp is installed")
+		log.Warn().Msgf("Could not configure logger, keeping the defaults:\n %v", err)
 	}
 
-	// Check if Warp service is enabled for startup and running
-	serv, err := warp.Serv.Init()
-	if err != nil {
-		log.Fatal().Msgf("Could not initialize Windows service manager with Warp service:\n %v", err)
+	// Log the enforcement events, keep the corrections in the audit trail and count them
+	events.Register(events.LogSink{}, audit.Sink{}, enforce.Metrics)
+
+	// Also send the logs to the configured log daemon
+	switch config.App().LogBackend {
+	case "journald":
+		err = logger.AddJournald(config.App().ServiceName)
+		if err == nil && logger.IsJournalStream() {
+			// The console would land in the journal a second time
+			logger.DisableConsole()
+		}
+	case "syslog":
+		err = logger.AddSyslog(config.App().SyslogAddress, config.App().ServiceName)
 	}
-	defer serv.Close()
-	err = serv.EnsureIsEnabled()
 	if err != nil {
-		log.Fatal().Msgf("Could not ensure Warp service is enabled for startup:\n %v", err)
-	} else {
-		log.Info().Msg("Warp service is enabled")
+		log.Warn().Msgf("Could not send logs to %s:\n %v", config.App().LogBackend, err)
 	}
-	err = serv.EnsureIsRunning()
-	if err != nil {
-		log.Fatal().Msgf("Could not ensure Warp service is running:\n %v", err)
-	} else {
-		log.Info().Msg("Warp service is running")
+
+	if flag.NArg() > 0 {
+		exit(runCommand(flag.Args()))
+	}
+
+	// Services installed by older versions are started without the 'run' command
+	isService, err := service.isService()
+	if err == nil && isService {
+		exit(runCommand([]string{"run"}))
 	}
+	interactive := !*nonInteractive && util.IsInteractive()
 
-	// Check if Warp service is connected to the Cloudflare service
-	err = warp.EnsureIsConnected()
+	err = config.Validate()
 	if err != nil {
-		log.Fatal().Msgf("Could not check Warp connection state to Cloudflare service:\n %v", err)
-	} else {
-		log.Info().Msg("Warp is connected to the Cloudflare service")
+		log.Error().Msgf("Invalid config:\n%v", err)
+		exit(exitError)
+	}
+
+	log.Info().Msg(color.New(color.Bold).Sprintf("WarpEnforcer starting..."))
+	if interactive {
+		util.WaitForInput()
+
+		// Ensure to run myself as admin
+		err = win.Admin.EnsureSelfAdmin()
+		if err != nil {
+			log.Fatal().Msgf("Could not ensure if I ran as admin:\n %v", err)
+		}
+	} else if !win.Admin.IsSelfAdmin() {
+		// Nobody could confirm the elevation prompt
+		log.Error().Msg("Not running as admin, rerun elevated")
+		exit(exitNotAdmin)
+	}
+
+	// Run the enforcement pipeline once
+	enforcer := enforce.New()
+	result := enforcer.Run(context.Background())
+	enforcer.Close()
+	for _, step := range result.Steps {
+		if step.OK {
+			log.Info().Msg(step.Message)
+		} else {
+			log.Error().Msgf("Step '%v' failed:\n %v", step.Step, step.Error)
+		}
+	}
+	if failed := result.Failed(); failed != nil {
+		exit(stepExitCode(failed.Step))
 	}
 
 	// Prevent app from being closed at the end
-	util.WaitForInput()
+	if interactive {
+		util.WaitForInput()
+	}
+	logger.Close()
+}
+
+// Flush the log file and exit with the given code
+func exit(code int) {
+	logger.Close()
+	os.Exit(code)
 }
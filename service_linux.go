@@ -0,0 +1,17 @@
+package main
+
+import (
+	linuxserv "github.com/ezydark/ezforce/libs/linux/serv"
+)
+
+// ezForce service backed by a systemd unit
+var service = serviceBackend{
+	install:   linuxserv.Install,
+	remove:    linuxserv.Remove,
+	start:     linuxserv.Start,
+	stop:      linuxserv.Stop,
+	status:    linuxserv.Status,
+	isService: linuxserv.IsService,
+	run:       linuxserv.Run,
+	debug:     linuxserv.Debug,
+}
@@ -0,0 +1,21 @@
+//go:build !windows && !linux
+
+package main
+
+import (
+	"errors"
+	"runtime"
+)
+
+var errServiceUnsupported = errors.New("managing the ezForce service is not supported on " + runtime.GOOS)
+
+var service = serviceBackend{
+	install:   func() error { return errServiceUnsupported },
+	remove:    func() error { return errServiceUnsupported },
+	start:     func() error { return errServiceUnsupported },
+	stop:      func() error { return errServiceUnsupported },
+	status:    func() (string, error) { return "", errServiceUnsupported },
+	isService: func() (bool, error) { return false, nil },
+	run:       func() error { return errServiceUnsupported },
+	debug:     func() error { return errServiceUnsupported },
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	winserv "github.com/ezydark/ezforce/libs/win/serv"
+)
+
+// ezForce service backed by the Windows service control manager
+var service = serviceBackend{
+	install:   winserv.Install,
+	remove:    winserv.Remove,
+	start:     winserv.Start,
+	stop:      winserv.Stop,
+	status:    winserv.Status,
+	isService: winserv.IsService,
+	run:       winserv.Run,
+	debug:     winserv.Debug,
+}